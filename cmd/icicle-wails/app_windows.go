@@ -5,7 +5,6 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/sha1"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,19 +24,28 @@ import (
 	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/registry"
 
+	"icicle/internal/dupes"
 	"icicle/internal/meta"
 	"icicle/internal/organize"
+	"icicle/internal/report"
 	"icicle/internal/scan"
+	"icicle/internal/skip"
 	"icicle/internal/ui"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 type HeavyItem struct {
-	Path  string `json:"path"`
-	Size  int64  `json:"size"`
-	Human string `json:"human"`
-	New   bool   `json:"new,omitempty"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Human      string    `json:"human"`
+	New        bool      `json:"new,omitempty"`
+	ModTime    time.Time `json:"mtime"`
+	AccessTime time.Time `json:"atime"`
+}
+
+func heavyItem(f scan.FileInfo) HeavyItem {
+	return HeavyItem{Path: f.Path, Size: f.Size, Human: ui.HumanBytes(f.Size), ModTime: f.ModTime, AccessTime: f.AccessTime}
 }
 
 type HeavyResult struct {
@@ -93,11 +101,6 @@ type Defaults struct {
 	Version   string `json:"version"`
 }
 
-type moveRecord struct {
-	From string
-	To   string
-}
-
 type userFolders struct {
 	Home      string
 	Downloads string
@@ -109,7 +112,6 @@ type App struct {
 	ctx      context.Context
 	appPath  string
 	mu       sync.Mutex
-	scanMu   sync.Mutex
 	logBuf   bytes.Buffer
 	watchCmd *exec.Cmd
 	watchOn  bool
@@ -117,19 +119,15 @@ type App struct {
 	folders userFolders
 	cfgPath string
 	saved   []string
-	moves   []moveRecord
 	tray    *trayBridge
 
-	fullScan struct {
-		Running    bool
-		Done       bool
-		Path       string
-		Seen       int
-		DurationMS int64
-		Items      []HeavyItem
-		Err        string
-	}
+	fullScan   fullScanState
 	fullCancel context.CancelFunc
+	scanCtx    context.Context
+	scanCancel context.CancelFunc
+	// wizTree is the last full WizMap scan; drilling into one of its folders reuses it.
+	wizTree   *scan.DirTree
+	wizTreeAt time.Time
 
 	driveHistory    map[string][]DriveHistoryPoint
 	schedule        scheduledScanState
@@ -137,6 +135,18 @@ type App struct {
 	heavySeenByRoot map[string]map[string]struct{}
 }
 
+type fullScanState struct {
+	Running    bool
+	Done       bool
+	Path       string
+	Seen       int
+	Total      int64
+	DurationMS int64
+	Items      []HeavyItem
+	Ext        []ExtStat
+	Err        string
+}
+
 type DriveHistoryPoint struct {
 	AtUnix int64 `json:"atUnix"`
 	Used   int64 `json:"used"`
@@ -267,6 +277,21 @@ type DuplicateActionResult struct {
 	GroupSize int         `json:"groupSize"`
 }
 
+type DuplicateKeepPlan struct {
+	KeptPath string   `json:"keptPath"`
+	Remove   []string `json:"remove"`
+	Skipped  int      `json:"skipped"`
+	// Differs counts copies left alone because their content is not the kept file's.
+	Differs int `json:"differs"`
+}
+
+type DuplicateKeepAllResult struct {
+	Rule    string              `json:"rule"`
+	DryRun  bool                `json:"dryRun"`
+	Plan    []DuplicateKeepPlan `json:"plan"`
+	Deleted BatchResult         `json:"deleted"`
+}
+
 type WatchHealthItem struct {
 	Path    string `json:"path"`
 	Status  string `json:"status"`
@@ -279,8 +304,11 @@ type HeavyFullProgress struct {
 	Done       bool        `json:"done"`
 	Path       string      `json:"path"`
 	Seen       int         `json:"seen"`
+	Total      int64       `json:"total"`
+	TotalHuman string      `json:"totalHuman"`
 	DurationMS int64       `json:"durationMs"`
 	Items      []HeavyItem `json:"items"`
+	Ext        []ExtStat   `json:"ext"`
 	Error      string      `json:"error"`
 }
 
@@ -314,6 +342,7 @@ func (a *App) startup(ctx context.Context) {
 }
 
 func (a *App) shutdown(context.Context) {
+	a.CancelScans()
 	a.StopWatch()
 	a.StopScheduledScan()
 	a.StopScheduledCleanup()
@@ -421,6 +450,10 @@ func (a *App) RunTree(path string, topN int, width int) (string, error) {
 }
 
 func (a *App) RunTreeFast(path string, topN int, width int, maxFiles int, workers int) (TreeResult, error) {
+	return a.runTreeFast(path, topN, width, maxFiles, workers, nil)
+}
+
+func (a *App) runTreeFast(path string, topN int, width int, maxFiles int, workers int, filter *scan.Filter) (TreeResult, error) {
 	path = a.normalizePath(path, a.folders.Home)
 	if topN <= 0 {
 		topN = 5
@@ -432,23 +465,14 @@ func (a *App) RunTreeFast(path string, topN int, width int, maxFiles int, worker
 		maxFiles = 0
 	}
 	started := time.Now()
-	a.scanMu.Lock()
-	prevWorkers, hadWorkers := os.LookupEnv("ICICLE_SCAN_WORKERS")
-	if workers > 0 {
-		_ = os.Setenv("ICICLE_SCAN_WORKERS", strconv.Itoa(workers))
-	}
-	stats, seen, limited, err := scan.ScanTreeLimited(path, topN, maxFiles)
-	if workers > 0 {
-		if hadWorkers {
-			_ = os.Setenv("ICICLE_SCAN_WORKERS", prevWorkers)
-		} else {
-			_ = os.Unsetenv("ICICLE_SCAN_WORKERS")
-		}
-	}
-	a.scanMu.Unlock()
+	opts := a.scanOptions(workers, maxFiles, nil)
+	opts.Filter = filter
+	stats, err := scan.ScanTreeContext(a.scanContext(), path, topN, opts)
 	if err != nil {
 		return TreeResult{}, err
 	}
+	a.logScanErrors(path, stats.Errors)
+	seen, limited := stats.Seen, stats.Limited
 	var b strings.Builder
 	theme := ui.Theme{NoColor: true, NoEmoji: true}
 	b.WriteString(fmt.Sprintf("%s  (total: %s)\n", path, ui.HumanBytes(stats.Total)))
@@ -508,7 +532,7 @@ func (a *App) RunHeavy(path string, n int) ([]HeavyItem, error) {
 	var b strings.Builder
 	b.WriteString("> heavy --n " + strconv.Itoa(n) + " " + path + "\n")
 	for _, f := range stats.TopFiles {
-		items = append(items, HeavyItem{Path: f.Path, Size: f.Size, Human: ui.HumanBytes(f.Size)})
+		items = append(items, heavyItem(f))
 		rel, relErr := filepath.Rel(path, f.Path)
 		if relErr != nil {
 			rel = f.Path
@@ -521,6 +545,10 @@ func (a *App) RunHeavy(path string, n int) ([]HeavyItem, error) {
 }
 
 func (a *App) RunHeavyFast(path string, n int, maxFiles int, workers int) (HeavyResult, error) {
+	return a.runHeavyFast(a.scanContext(), path, n, maxFiles, workers, nil, nil)
+}
+
+func (a *App) runHeavyFast(ctx context.Context, path string, n int, maxFiles int, workers int, idx *scan.Index, filter *scan.Filter) (HeavyResult, error) {
 	path = a.normalizePath(path, a.folders.Home)
 	if n <= 0 {
 		n = 20
@@ -530,32 +558,22 @@ func (a *App) RunHeavyFast(path string, n int, maxFiles int, workers int) (Heavy
 	}
 	started := time.Now()
 
-	a.scanMu.Lock()
-	prevWorkers, hadWorkers := os.LookupEnv("ICICLE_SCAN_WORKERS")
-	if workers > 0 {
-		_ = os.Setenv("ICICLE_SCAN_WORKERS", strconv.Itoa(workers))
-	}
-	stats, seen, limited, err := scan.ScanTopFilesLimited(path, n, maxFiles)
-	if workers > 0 {
-		if hadWorkers {
-			_ = os.Setenv("ICICLE_SCAN_WORKERS", prevWorkers)
-		} else {
-			_ = os.Unsetenv("ICICLE_SCAN_WORKERS")
-		}
-	}
-	a.scanMu.Unlock()
+	opts := a.scanOptions(workers, maxFiles, idx)
+	opts.Filter = filter
+	stats, err := scan.ScanTopFilesContext(ctx, path, n, opts)
 	if err != nil {
 		return HeavyResult{}, err
 	}
+	a.logScanErrors(path, stats.Errors)
 	items := make([]HeavyItem, 0, len(stats.TopFiles))
 	for _, f := range stats.TopFiles {
-		items = append(items, HeavyItem{Path: f.Path, Size: f.Size, Human: ui.HumanBytes(f.Size)})
+		items = append(items, heavyItem(f))
 	}
 	items = a.markNewHeavy(path, items)
 	out := HeavyResult{
 		Items:      items,
-		Seen:       seen,
-		Limited:    limited,
+		Seen:       stats.Seen,
+		Limited:    stats.Limited,
 		DurationMS: time.Since(started).Milliseconds(),
 	}
 	a.appendLog(fmt.Sprintf("> heavy --n %d %s [seen=%d limited=%v ms=%d]", n, path, out.Seen, out.Limited, out.DurationMS))
@@ -575,97 +593,60 @@ func (a *App) StartHeavyFullScan(path string, n int) error {
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	a.fullCancel = cancel
-	a.fullScan = struct {
-		Running    bool
-		Done       bool
-		Path       string
-		Seen       int
-		DurationMS int64
-		Items      []HeavyItem
-		Err        string
-	}{
+	a.fullScan = fullScanState{
 		Running: true,
 		Done:    false,
 		Path:    path,
 	}
 	a.mu.Unlock()
 
-	started := time.Now()
 	go func() {
-		top := scan.NewTopFiles(n)
-		seen := 0
-		lastPush := time.Now()
-		push := func(done bool, errText string) {
-			list := top.ListDesc()
-			items := make([]HeavyItem, 0, len(list))
-			for _, f := range list {
-				items = append(items, HeavyItem{Path: f.Path, Size: f.Size, Human: ui.HumanBytes(f.Size)})
+		push := func(p scan.Progress, errText string) {
+			items := make([]HeavyItem, 0, len(p.TopFiles))
+			for _, f := range p.TopFiles {
+				items = append(items, heavyItem(f))
+			}
+			ext := make([]ExtStat, 0, len(p.ExtStats))
+			for _, e := range p.ExtStats {
+				ext = append(ext, ExtStat{Ext: e.Ext, Count: e.Count, Size: e.Size, Human: ui.HumanBytes(e.Size)})
 			}
 			a.mu.Lock()
-			a.fullScan.Running = !done
-			a.fullScan.Done = done
+			a.fullScan.Running = !p.Done
+			a.fullScan.Done = p.Done
 			a.fullScan.Path = path
-			a.fullScan.Seen = seen
-			a.fullScan.DurationMS = time.Since(started).Milliseconds()
+			a.fullScan.Seen = p.Seen
+			a.fullScan.Total = p.Total
+			a.fullScan.DurationMS = p.Elapsed.Milliseconds()
 			a.fullScan.Items = items
+			a.fullScan.Ext = ext
 			a.fullScan.Err = errText
 			a.mu.Unlock()
+			if a.ctx != nil {
+				runtime.EventsEmit(a.ctx, "heavy:progress", a.GetHeavyFullProgress())
+			}
 		}
 
-		stopErr := fmt.Errorf("full scan cancelled")
-		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
-			select {
-			case <-ctx.Done():
-				return stopErr
-			default:
+		var final scan.Progress
+		for p := range scan.Stream(ctx, path, a.scanOptions(0, 0, nil), scan.StreamOptions{Interval: 300 * time.Millisecond, TopFiles: n, TopExt: 30}) {
+			if p.Done {
+				final = p
+				break
 			}
-			if err != nil {
-				if isDeniedError(err) {
-					if d != nil && d.IsDir() {
-						return filepath.SkipDir
-					}
-					return nil
-				}
-				return nil
-			}
-			if d != nil {
-				if d.IsDir() && shouldSkipDirName(strings.ToLower(d.Name())) {
-					return filepath.SkipDir
-				}
-				if d.Type()&os.ModeSymlink != 0 {
-					if d.IsDir() {
-						return filepath.SkipDir
-					}
-					return nil
-				}
-				if d.IsDir() {
-					return nil
-				}
-				info, ierr := d.Info()
-				if ierr != nil {
-					return nil
-				}
-				top.Push(scan.FileInfo{Path: p, Size: info.Size()})
-				seen++
-				if seen%400 == 0 || time.Since(lastPush) > 300*time.Millisecond {
-					push(false, "")
-					lastPush = time.Now()
-				}
-			}
-			return nil
-		})
-		if err != nil && err != stopErr {
-			push(true, err.Error())
-			a.appendLog("[full-heavy] failed: " + err.Error())
-			return
+			push(p, "")
 		}
-		if err == stopErr {
-			push(true, "cancelled")
+		if errors.Is(final.Err, context.Canceled) {
+			push(final, "cancelled")
 			a.appendLog("[full-heavy] cancelled")
 			return
 		}
-		push(true, "")
-		a.appendLog(fmt.Sprintf("[full-heavy] done: seen=%d ms=%d", seen, time.Since(started).Milliseconds()))
+		if final.Err != nil {
+			push(final, final.Err.Error())
+			a.appendLog("[full-heavy] failed: " + final.Err.Error())
+			return
+		}
+		push(final, "")
+		a.appendLog(fmt.Sprintf("[full-heavy] done: seen=%d ms=%d", final.Seen, final.Elapsed.Milliseconds()))
+		a.logScanErrors(path, final.Errors)
 	}()
 	return nil
 }
@@ -680,6 +661,29 @@ func (a *App) CancelHeavyFullScan() {
 	}
 }
 
+// CancelScans aborts every in-flight fast scan (tree, heavy, extensions, WizMap).
+func (a *App) CancelScans() {
+	a.mu.Lock()
+	cancel := a.scanCancel
+	a.scanCtx = nil
+	a.scanCancel = nil
+	a.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		a.appendLog("[scan] cancelled")
+	}
+}
+
+// scanContext returns the context shared by interactive scans until CancelScans is called.
+func (a *App) scanContext() context.Context {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.scanCtx == nil {
+		a.scanCtx, a.scanCancel = context.WithCancel(context.Background())
+	}
+	return a.scanCtx
+}
+
 func (a *App) GetHeavyFullProgress() HeavyFullProgress {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -688,6 +692,8 @@ func (a *App) GetHeavyFullProgress() HeavyFullProgress {
 		Done:       a.fullScan.Done,
 		Path:       a.fullScan.Path,
 		Seen:       a.fullScan.Seen,
+		Total:      a.fullScan.Total,
+		TotalHuman: ui.HumanBytes(a.fullScan.Total),
 		DurationMS: a.fullScan.DurationMS,
 		Error:      a.fullScan.Err,
 	}
@@ -695,6 +701,10 @@ func (a *App) GetHeavyFullProgress() HeavyFullProgress {
 		out.Items = make([]HeavyItem, len(a.fullScan.Items))
 		copy(out.Items, a.fullScan.Items)
 	}
+	if len(a.fullScan.Ext) > 0 {
+		out.Ext = make([]ExtStat, len(a.fullScan.Ext))
+		copy(out.Ext, a.fullScan.Ext)
+	}
 	return out
 }
 
@@ -734,34 +744,19 @@ func (a *App) ExportHeavy(path string, n int, format string) (string, error) {
 		return "", nil
 	}
 
-	var body string
-	switch format {
-	case "json":
-		b, err := json.MarshalIndent(items, "", "  ")
-		if err != nil {
+	var b bytes.Buffer
+	enc := report.NewEncoder(&b, report.Format(format), "heavy", path, report.HeavyColumns)
+	var total int64
+	for _, it := range items {
+		if err := enc.Row(report.HeavyRow(it.Path, it.Size, it.ModTime, it.AccessTime)...); err != nil {
 			return "", err
 		}
-		body = string(b) + "\n"
-	case "md":
-		var b strings.Builder
-		b.WriteString("| Size | Path |\n|---:|---|\n")
-		for _, it := range items {
-			b.WriteString("| " + it.Human + " | `" + strings.ReplaceAll(it.Path, "`", "'") + "` |\n")
-		}
-		body = b.String()
-	default:
-		var b strings.Builder
-		b.WriteString("size_bytes,size_human,path\n")
-		for _, it := range items {
-			b.WriteString(strconv.FormatInt(it.Size, 10))
-			b.WriteString(",")
-			b.WriteString(csvEscape(it.Human))
-			b.WriteString(",")
-			b.WriteString(csvEscape(it.Path))
-			b.WriteString("\n")
-		}
-		body = b.String()
+		total += it.Size
+	}
+	if err := enc.Close(report.SizeFields("shown", total)...); err != nil {
+		return "", err
 	}
+	body := b.String()
 	if err := os.WriteFile(target, []byte(body), 0o644); err != nil {
 		return "", err
 	}
@@ -833,6 +828,7 @@ func (a *App) WatchDiagnostics(path string, limit int) ([]WatchHealthItem, error
 		limit = 30
 	}
 	out := make([]WatchHealthItem, 0, limit+1)
+	skipDirs := a.skipPolicy().Bind(path)
 	check := func(p string) WatchHealthItem {
 		it := WatchHealthItem{Path: p, Status: "ok"}
 		entries, err := os.ReadDir(p)
@@ -864,7 +860,7 @@ func (a *App) WatchDiagnostics(path string, limit int) ([]WatchHealthItem, error
 		if !e.IsDir() {
 			continue
 		}
-		if shouldSkipDirName(strings.ToLower(e.Name())) {
+		if skipDirs.SkipDir(filepath.Join(path, e.Name()), e.Name()) {
 			out = append(out, WatchHealthItem{
 				Path:   filepath.Join(path, e.Name()),
 				Status: "skipped",
@@ -1058,14 +1054,21 @@ func (a *App) MoveFile(src string, dstDir string) (string, error) {
 		return "", fmt.Errorf("path is required")
 	}
 	dstDir = strings.TrimSpace(dstDir)
+	name := filepath.Base(src)
 	if dstDir == "" {
-		auto, ok := a.resolveAutoDestination(src)
+		auto, autoName, ok := a.resolveAutoDestination(src)
 		if !ok {
 			return "", fmt.Errorf("no auto destination for extension")
 		}
-		dstDir = auto
+		dstDir, name = auto, autoName
+	} else if strings.Contains(dstDir, "{") {
+		expanded, err := organize.ExpandTarget(dstDir, a.folders.Home, src)
+		if err != nil {
+			return "", err
+		}
+		dstDir = expanded
 	}
-	dst := filepath.Join(dstDir, filepath.Base(src))
+	dst := filepath.Join(dstDir, name)
 	uniqueDst, err := organize.EnsureUniquePath(dst)
 	if err != nil {
 		return "", err
@@ -1073,7 +1076,7 @@ func (a *App) MoveFile(src string, dstDir string) (string, error) {
 	if err := organize.MoveFile(src, uniqueDst); err != nil {
 		return "", err
 	}
-	a.pushMove(src, uniqueDst)
+	a.recordMove(src, uniqueDst)
 	a.appendLog("[move] " + src + " -> " + uniqueDst)
 	return uniqueDst, nil
 }
@@ -1146,24 +1149,36 @@ func (a *App) BatchDelete(paths []string, safe bool) BatchResult {
 	return res
 }
 
+// UndoMove reverses the most recent move in the shared journal that can still be undone,
+// including moves made by icicle watch. Newer moves whose file is gone or was changed are
+// skipped and logged, so they do not block the ones before them.
 func (a *App) UndoMove() (string, error) {
-	rec, ok := a.peekMove()
-	if !ok {
-		return "", fmt.Errorf("no move history")
-	}
-	if _, err := os.Stat(rec.To); err != nil {
-		return "", fmt.Errorf("moved file no longer exists: %s", rec.To)
-	}
-	target, err := organize.EnsureUniquePath(rec.From)
+	journal, err := organize.OpenJournal()
 	if err != nil {
 		return "", err
 	}
-	if err := organize.MoveFile(rec.To, target); err != nil {
+	pending, err := journal.Pending()
+	if err != nil {
 		return "", err
 	}
-	a.dropLastMove()
-	a.appendLog("[undo-move] " + rec.To + " -> " + target)
-	return target, nil
+	if len(pending) == 0 {
+		return "", fmt.Errorf("no move history")
+	}
+	var firstErr error
+	for i := len(pending) - 1; i >= 0; i-- {
+		m := pending[i]
+		undo, err := journal.Undo(m, organize.SourceGUI, false)
+		if err != nil {
+			a.appendLog(fmt.Sprintf("[undo-move] skipped %s %s: %v", m.ID, m.To, err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		a.appendLog("[undo-move] " + undo.From + " -> " + undo.To)
+		return undo.To, nil
+	}
+	return "", fmt.Errorf("none of the %d recorded moves can be undone: %w", len(pending), firstErr)
 }
 
 func (a *App) CleanEmpty(path string) (int, error) {
@@ -1212,6 +1227,7 @@ func (a *App) FindEmptyDirs(path string, limit int) ([]string, error) {
 		limit = 5000
 	}
 	dirs := make([]string, 0, 128)
+	skipDirs := a.skipPolicy().Bind(path)
 	err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
 		if err != nil {
 			if isDeniedError(err) {
@@ -1225,7 +1241,7 @@ func (a *App) FindEmptyDirs(path string, limit int) ([]string, error) {
 		if strings.EqualFold(filepath.Clean(p), filepath.Clean(path)) {
 			return nil
 		}
-		if shouldSkipDirName(d.Name()) {
+		if skipDirs.SkipDir(p, d.Name()) {
 			return filepath.SkipDir
 		}
 		entries, rerr := os.ReadDir(p)
@@ -1301,6 +1317,10 @@ func (a *App) DeleteEmptyDirsToRecycle(paths []string) BatchResult {
 }
 
 func (a *App) ScanCleanupPreset(path string, preset string, limit int, maxFiles int) (CleanupPresetResult, error) {
+	return a.scanCleanupPreset(a.scanContext(), path, preset, limit, maxFiles)
+}
+
+func (a *App) scanCleanupPreset(ctx context.Context, path string, preset string, limit int, maxFiles int) (CleanupPresetResult, error) {
 	path = a.normalizePath(path, a.folders.Home)
 	preset = strings.ToLower(strings.TrimSpace(preset))
 	if preset == "" {
@@ -1314,7 +1334,7 @@ func (a *App) ScanCleanupPreset(path string, preset string, limit int, maxFiles
 	}
 	out := CleanupPresetResult{Preset: preset}
 	candidates := make([]CleanupCandidate, 0, limit+64)
-	seen, err := scan.WalkAllLimit(path, maxFiles, func(p string, size int64) {
+	seen, err := scan.WalkAllContext(ctx, path, a.scanOptions(0, maxFiles, nil), func(p string, size int64) {
 		ok, reason := matchCleanupPreset(preset, p)
 		if !ok {
 			return
@@ -1421,7 +1441,7 @@ func cleanupRiskLevel(path string) string {
 func (a *App) ExtensionStats(path string, limit int) ([]ExtStat, error) {
 	path = a.normalizePath(path, a.folders.Home)
 	byExt := map[string]ExtStat{}
-	err := scan.WalkAll(path, func(p string, size int64) {
+	_, err := scan.WalkAllContext(a.scanContext(), path, a.scanOptions(0, 0, nil), func(p string, size int64) {
 		ext := strings.ToLower(filepath.Ext(p))
 		if ext == "" {
 			ext = "(no_ext)"
@@ -1448,6 +1468,10 @@ func (a *App) ExtensionStats(path string, limit int) ([]ExtStat, error) {
 }
 
 func (a *App) ExtensionStatsFast(path string, limit int, maxFiles int, workers int) (ExtStatsResult, error) {
+	return a.extensionStatsFast(path, limit, maxFiles, workers, nil)
+}
+
+func (a *App) extensionStatsFast(path string, limit int, maxFiles int, workers int, filter *scan.Filter) (ExtStatsResult, error) {
 	path = a.normalizePath(path, a.folders.Home)
 	if limit <= 0 {
 		limit = 20
@@ -1456,26 +1480,16 @@ func (a *App) ExtensionStatsFast(path string, limit int, maxFiles int, workers i
 		maxFiles = 0
 	}
 	started := time.Now()
-	a.scanMu.Lock()
-	prevWorkers, hadWorkers := os.LookupEnv("ICICLE_SCAN_WORKERS")
-	if workers > 0 {
-		_ = os.Setenv("ICICLE_SCAN_WORKERS", strconv.Itoa(workers))
-	}
-	items, seen, limited, err := scan.ScanExtStatsLimited(path, maxFiles)
-	if workers > 0 {
-		if hadWorkers {
-			_ = os.Setenv("ICICLE_SCAN_WORKERS", prevWorkers)
-		} else {
-			_ = os.Unsetenv("ICICLE_SCAN_WORKERS")
-		}
-	}
-	a.scanMu.Unlock()
+	opts := a.scanOptions(workers, maxFiles, nil)
+	opts.Filter = filter
+	stats, err := scan.ScanExtStatsContext(a.scanContext(), path, opts)
 	if err != nil {
 		return ExtStatsResult{}, err
 	}
+	a.logScanErrors(path, stats.Errors)
 
-	out := make([]ExtStat, 0, len(items))
-	for _, it := range items {
+	out := make([]ExtStat, 0, len(stats.Items))
+	for _, it := range stats.Items {
 		out = append(out, ExtStat{
 			Ext:   it.Ext,
 			Count: it.Count,
@@ -1488,8 +1502,8 @@ func (a *App) ExtensionStatsFast(path string, limit int, maxFiles int, workers i
 	}
 	res := ExtStatsResult{
 		Items:      out,
-		Seen:       seen,
-		Limited:    limited,
+		Seen:       stats.Seen,
+		Limited:    stats.Limited,
 		DurationMS: time.Since(started).Milliseconds(),
 	}
 	a.appendLog(fmt.Sprintf("[extensions-fast] %s seen=%d limited=%v ms=%d", path, res.Seen, res.Limited, res.DurationMS))
@@ -1511,52 +1525,79 @@ func (a *App) WizMap(path string, maxFiles int, workers int, topDirs int, topFil
 		topExt = 30
 	}
 	started := time.Now()
-	a.scanMu.Lock()
-	prevWorkers, hadWorkers := os.LookupEnv("ICICLE_SCAN_WORKERS")
-	if workers > 0 {
-		_ = os.Setenv("ICICLE_SCAN_WORKERS", strconv.Itoa(workers))
-	}
-	stats, err := scan.ScanOverviewLimited(path, maxFiles, topFiles, topExt)
-	if workers > 0 {
-		if hadWorkers {
-			_ = os.Setenv("ICICLE_SCAN_WORKERS", prevWorkers)
-		} else {
-			_ = os.Unsetenv("ICICLE_SCAN_WORKERS")
+
+	a.mu.Lock()
+	tree := a.wizTree
+	fresh := tree != nil && time.Since(a.wizTreeAt) < wizTreeTTL
+	a.mu.Unlock()
+	if fresh && filepath.Clean(path) != tree.Root.Name {
+		if node := tree.Find(path); node != nil {
+			res := wizMapFromTree(tree, node, topDirs, topFiles, topExt)
+			res.DurationMS = time.Since(started).Milliseconds()
+			a.appendLog(fmt.Sprintf("[wizmap] %s from tree cache ms=%d", path, res.DurationMS))
+			return res, nil
 		}
 	}
-	a.scanMu.Unlock()
+
+	tree, err := scan.ScanDirTreeContext(a.scanContext(), path, topFiles, a.scanOptions(workers, maxFiles, nil))
 	if err != nil {
 		return WizMapResult{}, err
 	}
-
-	type kv struct {
-		Name string
-		Size int64
-	}
-	children := make([]kv, 0, len(stats.ByChild))
-	for name, size := range stats.ByChild {
-		children = append(children, kv{Name: name, Size: size})
-	}
-	sort.Slice(children, func(i, j int) bool { return children[i].Size > children[j].Size })
-	if len(children) > topDirs {
-		children = children[:topDirs]
+	a.logScanErrors(path, tree.Errors)
+	if !tree.Limited {
+		a.mu.Lock()
+		a.wizTree = tree
+		a.wizTreeAt = time.Now()
+		a.mu.Unlock()
 	}
+	res := wizMapFromTree(tree, tree.Root, topDirs, topFiles, topExt)
+	res.DurationMS = time.Since(started).Milliseconds()
+	a.appendLog(fmt.Sprintf("[wizmap] %s seen=%d limited=%v ms=%d", path, res.Seen, res.Limited, res.DurationMS))
+	return res, nil
+}
 
-	rects := make([]VizRect, 0, len(children)+len(stats.TopFiles))
-	for _, c := range children {
-		full := filepath.Join(path, c.Name)
-		if c.Name == "(root)" {
-			full = path
-		}
+const wizTreeTTL = 2 * time.Minute
+
+// wizMapFromTree lays out one folder of a scanned tree. Extension stats are only known for the scan root.
+func wizMapFromTree(tree *scan.DirTree, node *scan.DirNode, topDirs int, topFiles int, topExt int) WizMapResult {
+	path := node.Path()
+	rects := make([]VizRect, 0, len(node.Children)+1+topFiles)
+	for _, c := range node.Children {
 		rects = append(rects, VizRect{
 			Name:  c.Name,
-			Path:  full,
+			Path:  filepath.Join(path, c.Name),
 			Kind:  "dir",
 			Size:  c.Size,
 			Human: ui.HumanBytes(c.Size),
 		})
 	}
-	for _, f := range stats.TopFiles {
+	if node.Own > 0 {
+		rects = append(rects, VizRect{
+			Name:  "(root)",
+			Path:  path,
+			Kind:  "dir",
+			Size:  node.Own,
+			Human: ui.HumanBytes(node.Own),
+		})
+	}
+	sort.SliceStable(rects, func(i, j int) bool { return rects[i].Size > rects[j].Size })
+	if len(rects) > topDirs {
+		rects = rects[:topDirs]
+	}
+
+	// The tree keeps one global top list; its entries under node are that folder's largest files.
+	prefix := path
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	files := 0
+	for _, f := range tree.TopFiles {
+		if files >= topFiles {
+			break
+		}
+		if node != tree.Root && !strings.HasPrefix(f.Path, prefix) {
+			continue
+		}
 		rects = append(rects, VizRect{
 			Name:  filepath.Base(f.Path),
 			Path:  f.Path,
@@ -1564,29 +1605,38 @@ func (a *App) WizMap(path string, maxFiles int, workers int, topDirs int, topFil
 			Size:  f.Size,
 			Human: ui.HumanBytes(f.Size),
 		})
+		files++
 	}
 
-	ext := make([]ExtStat, 0, len(stats.ExtStats))
-	for _, e := range stats.ExtStats {
-		ext = append(ext, ExtStat{
-			Ext:   e.Ext,
-			Count: e.Count,
-			Size:  e.Size,
-			Human: ui.HumanBytes(e.Size),
-		})
+	var ext []ExtStat
+	if node == tree.Root {
+		stats := tree.ExtStats
+		if len(stats) > topExt {
+			stats = stats[:topExt]
+		}
+		ext = make([]ExtStat, 0, len(stats))
+		for _, e := range stats {
+			ext = append(ext, ExtStat{
+				Ext:   e.Ext,
+				Count: e.Count,
+				Size:  e.Size,
+				Human: ui.HumanBytes(e.Size),
+			})
+		}
+	}
+	seen := node.Files
+	if node == tree.Root {
+		seen = tree.Seen
 	}
-	res := WizMapResult{
+	return WizMapResult{
 		Path:       path,
-		Total:      stats.Total,
-		TotalHuman: ui.HumanBytes(stats.Total),
-		Seen:       stats.Seen,
-		Limited:    stats.Limited,
-		DurationMS: time.Since(started).Milliseconds(),
+		Total:      node.Size,
+		TotalHuman: ui.HumanBytes(node.Size),
+		Seen:       seen,
+		Limited:    tree.Limited,
 		Rects:      rects,
 		Ext:        ext,
 	}
-	a.appendLog(fmt.Sprintf("[wizmap] %s seen=%d limited=%v ms=%d", path, res.Seen, res.Limited, res.DurationMS))
-	return res, nil
 }
 
 func (a *App) WizMapTurbo(path string, maxFiles int, topDirs int, topFiles int, topExt int) (WizMapResult, error) {
@@ -1692,13 +1742,8 @@ func (a *App) applySnapshotDeltaToWiz(res *WizMapResult, prev map[string]int64)
 
 func (a *App) DuplicateNames(path string, maxFiles int, top int) ([]DupStat, error) {
 	path = a.normalizePath(path, a.folders.Home)
-	files := 0
 	byName := map[string][]string{}
-	err := scan.WalkAll(path, func(p string, _ int64) {
-		files++
-		if maxFiles > 0 && files > maxFiles {
-			return
-		}
+	_, err := scan.WalkAllContext(a.scanContext(), path, a.scanOptions(0, maxFiles, nil), func(p string, _ int64) {
 		name := strings.ToLower(filepath.Base(p))
 		byName[name] = append(byName[name], p)
 	})
@@ -1732,18 +1777,23 @@ func (a *App) DuplicateFinderV2(path string, mode string, maxFiles int, top int)
 		top = 20
 	}
 
+	if mode == "hash" {
+		return a.duplicateHashGroups(path, maxFiles, top)
+	}
+	if mode == "similar" {
+		return a.duplicateSimilarGroups(path, maxFiles, top)
+	}
+	if mode == "dirs" {
+		return a.duplicateDirGroups(path, maxFiles, top)
+	}
+
 	type entry struct {
 		Path string
 		Size int64
 		Name string
 	}
 	files := make([]entry, 0, 4096)
-	count := 0
-	err := scan.WalkAll(path, func(p string, size int64) {
-		count++
-		if count > maxFiles {
-			return
-		}
+	_, err := scan.WalkAllContext(a.scanContext(), path, a.scanOptions(0, maxFiles, nil), func(p string, size int64) {
 		files = append(files, entry{
 			Path: p,
 			Size: size,
@@ -1755,30 +1805,9 @@ func (a *App) DuplicateFinderV2(path string, mode string, maxFiles int, top int)
 	}
 
 	groups := map[string][]entry{}
-	switch mode {
-	case "hash":
-		bySize := map[int64][]entry{}
-		for _, f := range files {
-			bySize[f.Size] = append(bySize[f.Size], f)
-		}
-		for size, bucket := range bySize {
-			if len(bucket) < 2 {
-				continue
-			}
-			for _, f := range bucket {
-				h, herr := hashFileQuick(f.Path)
-				if herr != nil {
-					continue
-				}
-				key := fmt.Sprintf("hash:%d:%s", size, h)
-				groups[key] = append(groups[key], f)
-			}
-		}
-	default:
-		for _, f := range files {
-			key := "name:" + f.Name
-			groups[key] = append(groups[key], f)
-		}
+	for _, f := range files {
+		key := "name:" + f.Name
+		groups[key] = append(groups[key], f)
 	}
 
 	out := make([]DupV2Group, 0, len(groups))
@@ -1812,15 +1841,229 @@ func (a *App) DuplicateFinderV2(path string, mode string, maxFiles int, top int)
 	return out, nil
 }
 
+// duplicateHashGroups only groups files whose whole content matches.
+// Hashes of unchanged files come from the shared hash cache.
+func (a *App) duplicateHashGroups(path string, maxFiles int, top int) ([]DupV2Group, error) {
+	cache, saveCache := a.openHashCache()
+	defer saveCache()
+	res, err := dupes.Find(a.scanContext(), path, dupes.Options{Scan: a.scanOptions(0, maxFiles, nil), Cache: cache})
+	if err != nil {
+		return nil, err
+	}
+	a.logScanErrors(path, res.Walk.Errors)
+	out := make([]DupV2Group, 0, len(res.Groups))
+	for _, g := range res.Groups {
+		paths := make([]string, 0, len(g.Files))
+		for _, f := range g.Files {
+			paths = append(paths, f.Path)
+		}
+		total := g.Size * int64(len(g.Files))
+		out = append(out, DupV2Group{
+			Key:   fmt.Sprintf("hash:%d:%s", g.Size, g.Hash),
+			Count: len(g.Files),
+			Total: total,
+			Human: ui.HumanBytes(total),
+			Paths: paths,
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Count > out[j].Count })
+	if len(out) > top {
+		out = out[:top]
+	}
+	a.appendLog(fmt.Sprintf("[dupes] %s groups=%d wasted=%s hashed=%s", path, len(res.Groups), ui.HumanBytes(res.Wasted), ui.HumanBytes(res.HashedSize)))
+	return out, nil
+}
+
+// openHashCache loads the shared hash cache; the returned func saves it. A cache that cannot
+// be opened is logged and left out.
+func (a *App) openHashCache() (*dupes.Cache, func()) {
+	cache, err := dupes.OpenCache()
+	if err != nil {
+		a.appendLog("[dupes] hash cache disabled: " + err.Error())
+		return nil, func() {}
+	}
+	return cache, func() {
+		if err := cache.Save(); err != nil {
+			a.appendLog("[dupes] hash cache save failed: " + err.Error())
+		}
+	}
+}
+
+// duplicateDirGroups reports identical folder trees; the paths of each group are folders.
+func (a *App) duplicateDirGroups(path string, maxFiles int, top int) ([]DupV2Group, error) {
+	cache, saveCache := a.openHashCache()
+	defer saveCache()
+	res, err := dupes.FindDirs(a.scanContext(), path, dupes.DirOptions{Scan: a.scanOptions(0, maxFiles, nil), Cache: cache})
+	if err != nil {
+		return nil, err
+	}
+	a.logScanErrors(path, res.Walk.Errors)
+	out := make([]DupV2Group, 0, len(res.Groups))
+	for _, g := range res.Groups {
+		total := g.Size * int64(len(g.Dirs))
+		out = append(out, DupV2Group{
+			Key:   fmt.Sprintf("dir:%d:%s", g.Size, g.Hash),
+			Count: len(g.Dirs),
+			Total: total,
+			Human: ui.HumanBytes(total),
+			Paths: g.Dirs,
+		})
+	}
+	if len(out) > top {
+		out = out[:top]
+	}
+	a.appendLog(fmt.Sprintf("[dupes] %s folder groups=%d wasted=%s", path, len(res.Groups), ui.HumanBytes(res.Wasted)))
+	return out, nil
+}
+
+// DuplicateFolderRemove keeps one folder of a duplicate folder group and moves the others to
+// the Recycle Bin. Every folder is hashed again first; nothing is removed unless all of them
+// are still identical.
+func (a *App) DuplicateFolderRemove(paths []string, rule string) (DuplicateActionResult, error) {
+	policy, err := dupes.ParseKeepRules(rule)
+	if err != nil {
+		return DuplicateActionResult{}, err
+	}
+	ctx := a.scanContext()
+	// DirHash walks without skip rules or filters, so every file and empty folder counts.
+	opts := dupes.DirOptions{}
+	dirs := make([]dupes.File, 0, len(paths))
+	want := ""
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		st, err := os.Stat(p)
+		if p == "" || err != nil || !st.IsDir() {
+			continue
+		}
+		hash, size, _, err := dupes.DirHash(ctx, p, opts)
+		if err != nil {
+			return DuplicateActionResult{}, fmt.Errorf("%s: %w", p, err)
+		}
+		if want == "" {
+			want = hash
+		} else if hash != want {
+			return DuplicateActionResult{}, fmt.Errorf("folders are no longer identical: %s", p)
+		}
+		dirs = append(dirs, dupes.File{Path: p, Size: size, ModTime: st.ModTime()})
+	}
+	if len(dirs) < 2 {
+		return DuplicateActionResult{}, fmt.Errorf("not enough valid folders")
+	}
+	keep := policy.Choose(dirs)
+	br := BatchResult{}
+	for i, d := range dirs {
+		if i == keep {
+			continue
+		}
+		br.Processed++
+		if err := deleteDirToRecycleBin(d.Path); err != nil {
+			br.Failed++
+			if len(br.Errors) < 20 {
+				br.Errors = append(br.Errors, fmt.Sprintf("%s: %v", d.Path, err))
+			}
+			continue
+		}
+		br.Succeeded++
+	}
+	a.appendLog(fmt.Sprintf("[dupe-dirs] rule=%s keep=%s recycled=%d/%d", policy, dirs[keep].Path, br.Succeeded, br.Processed))
+	return DuplicateActionResult{
+		Rule:      policy.String(),
+		KeptPath:  dirs[keep].Path,
+		Deleted:   br,
+		Skipped:   len(paths) - len(dirs),
+		GroupSize: len(paths),
+	}, nil
+}
+
+// duplicateSimilarGroups groups re-exported images and remuxed media. The key carries the
+// kind and the largest distance to the group's reference file, which is listed first.
+func (a *App) duplicateSimilarGroups(path string, maxFiles int, top int) ([]DupV2Group, error) {
+	res, err := dupes.FindSimilar(a.scanContext(), path, dupes.SimilarOptions{Scan: a.scanOptions(0, maxFiles, nil)})
+	if err != nil {
+		return nil, err
+	}
+	a.logScanErrors(path, res.Walk.Errors)
+	out := make([]DupV2Group, 0, len(res.Groups))
+	for _, g := range res.Groups {
+		paths := make([]string, 0, len(g.Files))
+		total := int64(0)
+		for _, f := range g.Files {
+			paths = append(paths, f.Path)
+			total += f.Size
+		}
+		out = append(out, DupV2Group{
+			Key:   fmt.Sprintf("similar:%s:%d:%s", g.Kind, g.MaxDistance, g.Files[0].Path),
+			Count: len(g.Files),
+			Total: total,
+			Human: ui.HumanBytes(total),
+			Paths: paths,
+		})
+	}
+	if len(out) > top {
+		out = out[:top]
+	}
+	a.appendLog(fmt.Sprintf("[dupes] %s similar groups=%d compared=%d unreadable=%d", path, len(res.Groups), res.Compared, res.Unreadable))
+	return out, nil
+}
+
 func (a *App) DuplicateKeep(paths []string, rule string, safe bool) (DuplicateActionResult, error) {
 	if len(paths) < 2 {
 		return DuplicateActionResult{}, fmt.Errorf("need at least 2 files in duplicate group")
 	}
-	type fileMeta struct {
-		Path string
-		Time time.Time
+	policy, err := dupes.ParseKeepRules(rule)
+	if err != nil {
+		return DuplicateActionResult{}, err
 	}
-	meta := make([]fileMeta, 0, len(paths))
+	plan := planDuplicateKeep(policy, paths)
+	if plan.KeptPath == "" {
+		return DuplicateActionResult{}, fmt.Errorf("not enough valid files")
+	}
+	if len(plan.Remove) == 0 {
+		return DuplicateActionResult{}, fmt.Errorf("no copy has the same content as %s", plan.KeptPath)
+	}
+	br := a.BatchDelete(plan.Remove, safe)
+	a.appendLog(fmt.Sprintf("[dupe-keep] rule=%s keep=%s deleted=%d/%d", policy, plan.KeptPath, br.Succeeded, br.Processed))
+	return DuplicateActionResult{
+		Rule:      policy.String(),
+		KeptPath:  plan.KeptPath,
+		Deleted:   br,
+		Skipped:   plan.Skipped + plan.Differs,
+		GroupSize: len(paths),
+	}, nil
+}
+
+// DuplicateKeepAll applies one keep rule to every group of a duplicate scan. Groups are
+// checked again on disk, so copies that are not byte-identical to the kept file stay. With
+// dryRun it only returns the plan.
+func (a *App) DuplicateKeepAll(groups [][]string, rule string, safe bool, dryRun bool) (DuplicateKeepAllResult, error) {
+	policy, err := dupes.ParseKeepRules(rule)
+	if err != nil {
+		return DuplicateKeepAllResult{}, err
+	}
+	res := DuplicateKeepAllResult{Rule: policy.String(), DryRun: dryRun, Plan: []DuplicateKeepPlan{}}
+	var remove []string
+	for _, paths := range groups {
+		plan := planDuplicateKeep(policy, paths)
+		if plan.KeptPath == "" || len(plan.Remove) == 0 {
+			continue
+		}
+		res.Plan = append(res.Plan, plan)
+		remove = append(remove, plan.Remove...)
+	}
+	if dryRun {
+		a.appendLog(fmt.Sprintf("[dupe-keep] plan rule=%s groups=%d remove=%d", policy, len(res.Plan), len(remove)))
+		return res, nil
+	}
+	res.Deleted = a.BatchDelete(remove, safe)
+	a.appendLog(fmt.Sprintf("[dupe-keep] rule=%s groups=%d deleted=%d/%d", policy, len(res.Plan), res.Deleted.Succeeded, res.Deleted.Processed))
+	return res, nil
+}
+
+// planDuplicateKeep picks the copy to keep among the paths that are still files. Only copies
+// that are byte-identical to the kept one are removed, whatever mode found the group.
+// KeptPath stays empty when fewer than two remain.
+func planDuplicateKeep(policy dupes.KeepPolicy, paths []string) DuplicateKeepPlan {
+	files := make([]dupes.File, 0, len(paths))
 	for _, p := range paths {
 		p = strings.TrimSpace(p)
 		if p == "" {
@@ -1830,36 +2073,29 @@ func (a *App) DuplicateKeep(paths []string, rule string, safe bool) (DuplicateAc
 		if err != nil || st.IsDir() {
 			continue
 		}
-		meta = append(meta, fileMeta{Path: p, Time: st.ModTime()})
-	}
-	if len(meta) < 2 {
-		return DuplicateActionResult{}, fmt.Errorf("not enough valid files")
-	}
-	rule = strings.ToLower(strings.TrimSpace(rule))
-	if rule == "" {
-		rule = "newest"
+		files = append(files, dupes.File{Path: p, Size: st.Size(), ModTime: st.ModTime()})
 	}
-	sort.Slice(meta, func(i, j int) bool { return meta[i].Time.Before(meta[j].Time) })
-	keep := meta[0].Path
-	if rule == "newest" {
-		keep = meta[len(meta)-1].Path
+	plan := DuplicateKeepPlan{Skipped: len(paths) - len(files), Remove: []string{}}
+	if len(files) < 2 {
+		return plan
 	}
-	toDelete := make([]string, 0, len(meta)-1)
-	for _, m := range meta {
-		if strings.EqualFold(m.Path, keep) {
+	keep := policy.Choose(files)
+	plan.KeptPath = files[keep].Path
+	for i, f := range files {
+		if i == keep {
 			continue
 		}
-		toDelete = append(toDelete, m.Path)
+		same, err := dupes.SameContent(plan.KeptPath, f.Path)
+		switch {
+		case err != nil:
+			plan.Skipped++
+		case !same:
+			plan.Differs++
+		default:
+			plan.Remove = append(plan.Remove, f.Path)
+		}
 	}
-	br := a.BatchDelete(toDelete, safe)
-	a.appendLog(fmt.Sprintf("[dupe-keep] rule=%s keep=%s deleted=%d/%d", rule, keep, br.Succeeded, br.Processed))
-	return DuplicateActionResult{
-		Rule:      rule,
-		KeptPath:  keep,
-		Deleted:   br,
-		Skipped:   len(paths) - len(meta),
-		GroupSize: len(paths),
-	}, nil
+	return plan
 }
 
 func (a *App) StartScheduledScan(path string, intervalSec int, n int, maxFiles int, workers int) error {
@@ -1915,6 +2151,8 @@ func (a *App) RunScheduledScanOnce(path string, n int, maxFiles int, workers int
 }
 
 func (a *App) scheduledLoop(ctx context.Context) {
+	// Repeat runs over the same folder only re-read directories that changed since the last one.
+	var idx *scan.Index
 	run := func() {
 		a.mu.Lock()
 		st := a.schedule
@@ -1924,8 +2162,23 @@ func (a *App) scheduledLoop(ctx context.Context) {
 		maxFiles := st.MaxFiles
 		workers := st.Workers
 		started := time.Now()
-		res, err := a.RunHeavyFast(path, n, maxFiles, workers)
+		if idx == nil || idx.Root() != filepath.Clean(path) {
+			var ierr error
+			if idx, ierr = scan.OpenIndex(path); ierr != nil {
+				a.appendLog("[schedule] index disabled: " + ierr.Error())
+			}
+		}
+		res, err := a.runHeavyFast(ctx, path, n, maxFiles, workers, idx, nil)
+		if idx != nil {
+			if ierr := idx.Save(); ierr != nil {
+				a.appendLog("[schedule] index save failed: " + ierr.Error())
+			}
+		}
 		status := "ok"
+		if ctx.Err() != nil {
+			a.appendLog("[schedule] run cancelled")
+			return
+		}
 		if err != nil {
 			status = "error: " + err.Error()
 		} else {
@@ -2420,37 +2673,21 @@ func (a *App) markNewHeavy(root string, items []HeavyItem) []HeavyItem {
 	return items
 }
 
-func (a *App) pushMove(from, to string) {
+// recordMove adds a completed move to the shared journal so it can be undone later.
+func (a *App) recordMove(from, to string) {
 	if abs, err := filepath.Abs(from); err == nil {
 		from = abs
 	}
 	if abs, err := filepath.Abs(to); err == nil {
 		to = abs
 	}
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	a.moves = append(a.moves, moveRecord{From: from, To: to})
-	if len(a.moves) > 200 {
-		a.moves = a.moves[len(a.moves)-200:]
-	}
-}
-
-func (a *App) peekMove() (moveRecord, bool) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	if len(a.moves) == 0 {
-		return moveRecord{}, false
+	journal, err := organize.OpenJournal()
+	if err == nil {
+		_, err = journal.Record(organize.SourceGUI, from, to)
 	}
-	return a.moves[len(a.moves)-1], true
-}
-
-func (a *App) dropLastMove() {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	if len(a.moves) == 0 {
-		return
+	if err != nil {
+		a.appendLog("[journal] " + err.Error())
 	}
-	a.moves = a.moves[:len(a.moves)-1]
 }
 
 func (a *App) loadSaved() error {
@@ -2501,11 +2738,6 @@ func dedupePaths(in []string) []string {
 	return out
 }
 
-func csvEscape(s string) string {
-	s = strings.ReplaceAll(s, `"`, `""`)
-	return `"` + s + `"`
-}
-
 type driveUsage struct {
 	Drive string
 	Total int64
@@ -2723,28 +2955,21 @@ func isDeniedError(err error) bool {
 	return strings.Contains(msg, "access is denied") || strings.Contains(msg, "permission denied")
 }
 
-func shouldSkipDirName(name string) bool {
-	name = strings.ToLower(strings.TrimSpace(name))
-	switch name {
-	case "$recycle.bin", "system volume information", "windowsapps", "$winreagent", "$extend":
-		return true
-	default:
-		return false
+// skipPolicy is the shared skip.json policy used by every scan, watch and cleanup in the app.
+func (a *App) skipPolicy() *skip.Policy {
+	p, err := skip.Load()
+	if err != nil {
+		a.appendLog("[skip] config error: " + err.Error())
 	}
+	return p
 }
 
-func hashFileQuick(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
+func (a *App) logScanErrors(path string, errs scan.ScanErrors) {
+	if errs.Total() > 0 {
+		a.appendLog(fmt.Sprintf("[scan] %s: skipped %s", path, errs.Summary()))
 	}
-	defer f.Close()
-	h := sha1.New()
-	buf := make([]byte, 1024*1024)
-	n, err := io.ReadFull(f, buf)
-	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-		return "", err
-	}
-	_, _ = h.Write(buf[:n])
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (a *App) scanOptions(workers int, maxFiles int, idx *scan.Index) scan.Options {
+	return scan.Options{Workers: workers, MaxFiles: maxFiles, Index: idx, Skip: a.skipPolicy()}
 }
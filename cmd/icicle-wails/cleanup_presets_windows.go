@@ -14,6 +14,8 @@ import (
 	"time"
 
 	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"icicle/internal/organize"
 )
 
 type DiskCleanupPreset struct {
@@ -158,7 +160,7 @@ type TeamPresetPack struct {
 	ExportedAt     int64                        `json:"exportedAt"`
 	Name           string                       `json:"name"`
 	CleanupByDrive map[string]DiskCleanupPreset `json:"cleanupByDrive"`
-	RouteRules     []RouteRule                  `json:"routeRules"`
+	RouteRules     []organize.RouteRule         `json:"routeRules"`
 	SavedFolders   []string                     `json:"savedFolders"`
 }
 
@@ -289,7 +291,7 @@ func (a *App) applyTeamPresetPack(pack TeamPresetPack, mode string, source strin
 		return err
 	}
 	currentRules, _ := a.ListRoutingRules()
-	rules := normalizeRouteRules(pack.RouteRules)
+	rules := organize.NormalizeRouteRules(pack.RouteRules)
 	if mode != "overwrite" {
 		rules = mergeRouteRules(currentRules, rules)
 	}
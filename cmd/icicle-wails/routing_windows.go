@@ -3,11 +3,7 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -17,16 +13,6 @@ import (
 	"icicle/internal/ui"
 )
 
-type RouteRule struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Enabled  bool   `json:"enabled"`
-	Kind     string `json:"kind"` // ext|contains|prefix|regex
-	Pattern  string `json:"pattern"`
-	Target   string `json:"target"`
-	Priority int    `json:"priority"`
-}
-
 type RouteMatch struct {
 	Path    string `json:"path"`
 	Matched bool   `json:"matched"`
@@ -64,42 +50,21 @@ type RouteSimulationReport struct {
 	UnmatchedSamples []string                  `json:"unmatchedSamples"`
 }
 
-func (a *App) routeRulesPath() string {
-	cfgDir, _ := os.UserConfigDir()
-	if strings.TrimSpace(cfgDir) == "" {
-		cfgDir = a.folders.Home
-	}
-	return filepath.Join(cfgDir, "icicle", "routing_rules.json")
-}
-
-func (a *App) ListRoutingRules() ([]RouteRule, error) {
-	path := a.routeRulesPath()
-	data, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		return []RouteRule{}, nil
-	}
+func (a *App) ListRoutingRules() ([]organize.RouteRule, error) {
+	path, err := organize.RouteRulesPath()
 	if err != nil {
 		return nil, err
 	}
-	var rules []RouteRule
-	if err := json.Unmarshal(data, &rules); err != nil {
-		return nil, err
-	}
-	out := normalizeRouteRules(rules)
-	return out, nil
+	return organize.LoadRouteRules(path)
 }
 
-func (a *App) SaveRoutingRules(rules []RouteRule) error {
-	rules = normalizeRouteRules(rules)
-	data, err := json.MarshalIndent(rules, "", "  ")
+func (a *App) SaveRoutingRules(rules []organize.RouteRule) error {
+	path, err := organize.RouteRulesPath()
 	if err != nil {
 		return err
 	}
-	path := a.routeRulesPath()
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
-	}
-	if err := os.WriteFile(path, data, 0o644); err != nil {
+	rules, err = organize.SaveRouteRules(path, rules)
+	if err != nil {
 		return err
 	}
 	a.appendLog(fmt.Sprintf("[routing] rules saved: %d", len(rules)))
@@ -115,22 +80,12 @@ func (a *App) TestRouting(path string) (RouteMatch, error) {
 	if err != nil {
 		return RouteMatch{}, err
 	}
-	for _, r := range rules {
-		if !r.Enabled {
-			continue
-		}
-		if routeRuleMatches(r, path) {
-			target := expandRouteTarget(r.Target, a.folders.Home)
-			if target == "" {
-				continue
-			}
-			return RouteMatch{Path: path, Matched: true, RuleID: r.ID, Rule: r.Name, Target: target}, nil
-		}
-	}
-	if auto, ok := organize.DestinationDir(a.folders.Home, path); ok {
-		return RouteMatch{Path: path, Matched: true, RuleID: "builtin", Rule: "builtin-extension", Target: auto}, nil
+	organize.SortRouteRules(rules)
+	target, r, ok := organize.Route(rules, a.folders.Home, path)
+	if !ok {
+		return RouteMatch{Path: path}, nil
 	}
-	return RouteMatch{Path: path}, nil
+	return RouteMatch{Path: path, Matched: true, RuleID: r.ID, Rule: r.Name, Target: target}, nil
 }
 
 func (a *App) SimulateRoutingSamples(raw string) ([]RouteMatch, error) {
@@ -164,7 +119,7 @@ func (a *App) SimulateRoutingForPath(path string, maxFiles int) (RouteSimulation
 	if err != nil {
 		return RouteSimulationReport{}, err
 	}
-	sortRouteRules(rules)
+	organize.SortRouteRules(rules)
 
 	report := RouteSimulationReport{
 		Path:             path,
@@ -179,21 +134,9 @@ func (a *App) SimulateRoutingForPath(path string, maxFiles int) (RouteSimulation
 		byRule[r.ID] = &RouteSimulationRuleStat{RuleID: r.ID, Rule: r.Name}
 	}
 
-	count, err := scan.WalkAllLimit(path, maxFiles, func(p string, size int64) {
+	count, err := scan.WalkAllContext(a.scanContext(), path, a.scanOptions(0, maxFiles, nil), func(p string, size int64) {
 		report.Seen++
-		matched := false
-		for _, r := range rules {
-			if !r.Enabled {
-				continue
-			}
-			if !routeRuleMatches(r, p) {
-				continue
-			}
-			target := expandRouteTarget(r.Target, a.folders.Home)
-			if strings.TrimSpace(target) == "" {
-				continue
-			}
-			matched = true
+		if _, r, ok := organize.Route(rules, a.folders.Home, p); ok {
 			report.Matched++
 			report.MatchedSize += size
 			stat := byRule[r.ID]
@@ -203,21 +146,6 @@ func (a *App) SimulateRoutingForPath(path string, maxFiles int) (RouteSimulation
 			}
 			stat.Matched++
 			stat.TotalSize += size
-			break
-		}
-		if matched {
-			return
-		}
-		if _, ok := organize.DestinationDir(a.folders.Home, p); ok {
-			report.Matched++
-			report.MatchedSize += size
-			stat := byRule["builtin"]
-			if stat == nil {
-				stat = &RouteSimulationRuleStat{RuleID: "builtin", Rule: "builtin-extension"}
-				byRule["builtin"] = stat
-			}
-			stat.Matched++
-			stat.TotalSize += size
 			return
 		}
 		report.Unmatched++
@@ -251,31 +179,16 @@ func (a *App) SimulateRoutingForPath(path string, maxFiles int) (RouteSimulation
 	return report, nil
 }
 
-func (a *App) resolveAutoDestination(src string) (string, bool) {
-	rules, err := a.ListRoutingRules()
-	if err == nil {
-		sortRouteRules(rules)
-		for _, r := range rules {
-			if !r.Enabled {
-				continue
-			}
-			if !routeRuleMatches(r, src) {
-				continue
-			}
-			target := expandRouteTarget(r.Target, a.folders.Home)
-			if strings.TrimSpace(target) == "" {
-				continue
-			}
-			return target, true
-		}
+// resolveAutoDestination returns the folder and file name src is routed to.
+func (a *App) resolveAutoDestination(src string) (string, string, bool) {
+	// An unreadable rules file leaves the built-in routing.
+	rules, _ := a.ListRoutingRules()
+	organize.SortRouteRules(rules)
+	target, rule, ok := organize.Route(rules, a.folders.Home, src)
+	if !ok {
+		return "", "", false
 	}
-	return organize.DestinationDir(a.folders.Home, src)
-}
-
-func sortRouteRules(rules []RouteRule) {
-	sort.SliceStable(rules, func(i, j int) bool {
-		return rules[i].Priority < rules[j].Priority
-	})
+	return target, rule.TargetName(src), true
 }
 
 func (a *App) DetectRoutingConflicts() ([]RouteConflict, error) {
@@ -295,7 +208,7 @@ func (a *App) DetectRoutingConflicts() ([]RouteConflict, error) {
 				continue
 			}
 			if strings.EqualFold(ri.Kind, rj.Kind) && strings.EqualFold(strings.TrimSpace(ri.Pattern), strings.TrimSpace(rj.Pattern)) {
-				if strings.EqualFold(expandRouteTarget(ri.Target, a.folders.Home), expandRouteTarget(rj.Target, a.folders.Home)) {
+				if strings.EqualFold(organize.ExpandRouteTarget(ri.Target, a.folders.Home), organize.ExpandRouteTarget(rj.Target, a.folders.Home)) {
 					continue
 				}
 				out = append(out, RouteConflict{
@@ -338,12 +251,12 @@ func (a *App) DetectRoutingConflicts() ([]RouteConflict, error) {
 	return out, nil
 }
 
-func (a *App) AutoResolveRoutingPriorities() ([]RouteRule, error) {
+func (a *App) AutoResolveRoutingPriorities() ([]organize.RouteRule, error) {
 	rules, err := a.ListRoutingRules()
 	if err != nil {
 		return nil, err
 	}
-	score := func(r RouteRule) int {
+	score := func(r organize.RouteRule) int {
 		k := strings.ToLower(strings.TrimSpace(r.Kind))
 		p := strings.TrimSpace(r.Pattern)
 		switch k {
@@ -375,73 +288,3 @@ func (a *App) AutoResolveRoutingPriorities() ([]RouteRule, error) {
 	}
 	return rules, nil
 }
-
-func normalizeRouteRules(in []RouteRule) []RouteRule {
-	out := make([]RouteRule, 0, len(in))
-	seen := map[string]bool{}
-	for i, r := range in {
-		r.Name = strings.TrimSpace(r.Name)
-		r.Kind = strings.ToLower(strings.TrimSpace(r.Kind))
-		r.Pattern = strings.TrimSpace(r.Pattern)
-		r.Target = strings.TrimSpace(r.Target)
-		if r.Name == "" {
-			r.Name = fmt.Sprintf("Rule %d", i+1)
-		}
-		if r.Kind == "" {
-			r.Kind = "ext"
-		}
-		if r.ID == "" {
-			r.ID = fmt.Sprintf("rule-%d", i+1)
-		}
-		if seen[strings.ToLower(r.ID)] {
-			continue
-		}
-		if r.Pattern == "" || r.Target == "" {
-			continue
-		}
-		seen[strings.ToLower(r.ID)] = true
-		out = append(out, r)
-	}
-	return out
-}
-
-func routeRuleMatches(rule RouteRule, path string) bool {
-	raw := strings.TrimSpace(path)
-	if raw == "" {
-		return false
-	}
-	p := strings.ToLower(raw)
-	pat := strings.ToLower(strings.TrimSpace(rule.Pattern))
-	if pat == "" {
-		return false
-	}
-	switch rule.Kind {
-	case "contains":
-		return strings.Contains(p, pat)
-	case "prefix":
-		return strings.HasPrefix(p, pat)
-	case "regex":
-		re, err := regexp.Compile(rule.Pattern)
-		if err != nil {
-			return false
-		}
-		return re.MatchString(raw)
-	default:
-		ext := strings.ToLower(filepath.Ext(raw))
-		if !strings.HasPrefix(pat, ".") {
-			pat = "." + pat
-		}
-		return ext == pat
-	}
-}
-
-func expandRouteTarget(target string, home string) string {
-	target = strings.TrimSpace(target)
-	if target == "" {
-		return ""
-	}
-	target = os.ExpandEnv(target)
-	target = strings.ReplaceAll(target, "{home}", home)
-	target = filepath.Clean(target)
-	return target
-}
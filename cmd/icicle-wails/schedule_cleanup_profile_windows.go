@@ -17,6 +17,8 @@ import (
 	"time"
 
 	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"icicle/internal/organize"
 )
 
 type CleanupScheduleStatus struct {
@@ -154,11 +156,15 @@ func (a *App) ScheduledCleanupStatus() CleanupScheduleStatus {
 }
 
 func (a *App) RunScheduledCleanupOnce(path string, preset string, safe bool, dryRun bool, maxDelete int) (BatchResult, error) {
+	return a.runScheduledCleanupOnce(a.scanContext(), path, preset, safe, dryRun, maxDelete)
+}
+
+func (a *App) runScheduledCleanupOnce(ctx context.Context, path string, preset string, safe bool, dryRun bool, maxDelete int) (BatchResult, error) {
 	path = a.normalizePath(path, a.folders.Downloads)
 	if maxDelete <= 0 {
 		maxDelete = 150
 	}
-	res, err := a.ScanCleanupPreset(path, preset, maxDelete, 0)
+	res, err := a.scanCleanupPreset(ctx, path, preset, maxDelete, 0)
 	if err != nil {
 		return BatchResult{}, err
 	}
@@ -183,8 +189,11 @@ func (a *App) cleanupLoop(ctx context.Context) {
 		a.mu.Lock()
 		st := a.cleanup
 		a.mu.Unlock()
-		br, err := a.RunScheduledCleanupOnce(st.Path, st.Preset, st.Safe, st.DryRun, st.MaxDelete)
+		br, err := a.runScheduledCleanupOnce(ctx, st.Path, st.Preset, st.Safe, st.DryRun, st.MaxDelete)
 		status := "ok"
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
 			status = "error: " + err.Error()
 		} else {
@@ -265,9 +274,9 @@ type encryptedProfile struct {
 }
 
 type profilePayload struct {
-	SavedFolders []string    `json:"savedFolders"`
-	RouteRules   []RouteRule `json:"routeRules"`
-	ExportedAt   int64       `json:"exportedAt"`
+	SavedFolders []string             `json:"savedFolders"`
+	RouteRules   []organize.RouteRule `json:"routeRules"`
+	ExportedAt   int64                `json:"exportedAt"`
 }
 
 func (a *App) ExportProfileEncrypted(passphrase string) (string, error) {
@@ -384,7 +393,7 @@ func (a *App) ImportProfileEncryptedWithMode(passphrase string, mode string) (st
 		return "", err
 	}
 	pl.SavedFolders = dedupePaths(pl.SavedFolders)
-	pl.RouteRules = normalizeRouteRules(pl.RouteRules)
+	pl.RouteRules = organize.NormalizeRouteRules(pl.RouteRules)
 	a.mu.Lock()
 	currentSaved := make([]string, len(a.saved))
 	copy(currentSaved, a.saved)
@@ -411,10 +420,10 @@ func (a *App) ImportProfileEncryptedWithMode(passphrase string, mode string) (st
 	return source, nil
 }
 
-func mergeRouteRules(base []RouteRule, incoming []RouteRule) []RouteRule {
+func mergeRouteRules(base []organize.RouteRule, incoming []organize.RouteRule) []organize.RouteRule {
 	seen := map[string]bool{}
-	out := make([]RouteRule, 0, len(base)+len(incoming))
-	for _, r := range normalizeRouteRules(base) {
+	out := make([]organize.RouteRule, 0, len(base)+len(incoming))
+	for _, r := range organize.NormalizeRouteRules(base) {
 		key := strings.ToLower(strings.TrimSpace(r.ID))
 		if key == "" {
 			key = strings.ToLower(r.Name + "|" + r.Kind + "|" + r.Pattern + "|" + r.Target)
@@ -425,7 +434,7 @@ func mergeRouteRules(base []RouteRule, incoming []RouteRule) []RouteRule {
 		seen[key] = true
 		out = append(out, r)
 	}
-	for _, r := range normalizeRouteRules(incoming) {
+	for _, r := range organize.NormalizeRouteRules(incoming) {
 		key := strings.ToLower(strings.TrimSpace(r.ID))
 		if key == "" {
 			key = strings.ToLower(r.Name + "|" + r.Kind + "|" + r.Pattern + "|" + r.Target)
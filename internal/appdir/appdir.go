@@ -0,0 +1,26 @@
+// Package appdir locates icicle's per-user state directory.
+package appdir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir returns <user config dir>/icicle joined with elem, creating it if needed.
+// It falls back to the home directory when the platform has no config dir.
+func Dir(elem ...string) (string, error) {
+	base, _ := os.UserConfigDir()
+	if strings.TrimSpace(base) == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = home
+	}
+	dir := filepath.Join(append([]string{base, "icicle"}, elem...)...)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -1,19 +1,287 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
+
+	"icicle/internal/report"
+	"icicle/internal/scan"
+	"icicle/internal/skip"
+	"icicle/internal/ui"
 )
 
 type commonFlags struct {
 	noColor bool
 	noEmoji bool
+	format  report.Format
 }
 
 func addCommonFlags(fs *flag.FlagSet, c *commonFlags) {
 	fs.BoolVar(&c.noColor, "no-color", false, "disable ANSI colors")
 	fs.BoolVar(&c.noEmoji, "no-emoji", false, "disable emoji in output")
+	c.format = report.Text
+	fs.Func("format", "output format: text, json, ndjson, csv or md", func(v string) error {
+		f, err := report.ParseFormat(v)
+		c.format = f
+		return err
+	})
+}
+
+// structured reports whether output goes through the report encoder instead of human text.
+func (c commonFlags) structured() bool {
+	return c.format != report.Text
+}
+
+type scanFlags struct {
+	workers        int
+	maxFiles       int
+	followSymlinks bool
+	useIndex       bool
+	sizeMode       scan.SizeMode
+	topBy          scan.TopOrder
+	skip           skipFlags
+	filter         filterFlags
+	strict         bool
+
+	index *scan.Index
+}
+
+func addScanFlags(fs *flag.FlagSet, c *scanFlags) {
+	fs.IntVar(&c.workers, "workers", 0, "parallel directory readers (0 = auto)")
+	fs.IntVar(&c.maxFiles, "max-files", 0, "stop after N files (0 = no limit)")
+	fs.BoolVar(&c.followSymlinks, "follow-symlinks", false, "follow symlinked files and folders")
+	fs.BoolVar(&c.useIndex, "index", false, "reuse the on-disk index and only re-read changed folders")
+	addSkipFlags(fs, &c.skip)
+	addFilterFlags(fs, &c.filter)
+	addRangeFlags(fs, &c.filter)
+	fs.BoolVar(&c.strict, "strict", false, "abort on the first unreadable file or folder other than access denied")
+	fs.Func("size", "size to show and sum: apparent or allocated (disk usage)", func(v string) error {
+		mode, err := scan.ParseSizeMode(v)
+		c.sizeMode = mode
+		return err
+	})
+}
+
+func (c scanFlags) options() scan.Options {
+	return scan.Options{
+		Workers:        c.workers,
+		MaxFiles:       c.maxFiles,
+		FollowSymlinks: c.followSymlinks,
+		Index:          c.index,
+		SizeMode:       c.sizeMode,
+		TopBy:          c.topBy,
+		Skip:           c.skip.policy(),
+		Filter:         c.filter.compiled,
+		Strict:         c.strict,
+	}
+}
+
+// skipFlags extend the shared skip.json policy for one run.
+type skipFlags struct {
+	patterns      stringList
+	oneFileSystem bool
+}
+
+func addSkipFlags(fs *flag.FlagSet, c *skipFlags) {
+	fs.Var(&c.patterns, "skip", "skip folders matching this glob (name or full path; repeatable)")
+	fs.BoolVar(&c.oneFileSystem, "one-file-system", false, "do not cross into other mounted filesystems")
+}
+
+func (c skipFlags) policy() *skip.Policy {
+	p, err := skip.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "skip config error: %v\n", err)
+	}
+	p.Patterns = append(p.Patterns, c.patterns...)
+	if c.oneFileSystem {
+		p.OneFileSystem = true
+	}
+	return p
+}
+
+// filterFlags select which files are counted or handled.
+type filterFlags struct {
+	include    stringList
+	exclude    stringList
+	includeExt stringList
+	excludeExt stringList
+	minSize    int64
+	maxSize    int64
+	olderThan  time.Duration
+	newerThan  time.Duration
+
+	compiled *scan.Filter
+}
+
+func addFilterFlags(fs *flag.FlagSet, c *filterFlags) {
+	fs.Var(&c.include, "include", "only files whose path matches: text, glob or re:REGEX (repeatable)")
+	fs.Var(&c.exclude, "exclude", "leave out files whose path matches: text, glob or re:REGEX (repeatable)")
+	fs.Var(&c.includeExt, "include-ext", "only these extensions, e.g. .mp4,.mkv (repeatable)")
+	fs.Var(&c.excludeExt, "exclude-ext", "leave out these extensions (repeatable)")
+}
+
+// addRangeFlags adds the size and age bounds used by scans.
+func addRangeFlags(fs *flag.FlagSet, c *filterFlags) {
+	fs.Func("min-size", "only files of at least this size, e.g. 500MB", sizeFlag(&c.minSize))
+	fs.Func("max-size", "only files of at most this size, e.g. 4GB", sizeFlag(&c.maxSize))
+	fs.Func("older-than", "only files modified longer ago than this, e.g. 90d, 6mo", ageFlag(&c.olderThan))
+	fs.Func("newer-than", "only files modified within this, e.g. 7d, 12h", ageFlag(&c.newerThan))
+}
+
+func sizeFlag(dst *int64) func(string) error {
+	return func(v string) error {
+		n, err := scan.ParseSize(v)
+		*dst = n
+		return err
+	}
+}
+
+func ageFlag(dst *time.Duration) func(string) error {
+	return func(v string) error {
+		d, err := scan.ParseAge(v)
+		*dst = d
+		return err
+	}
+}
+
+// compile checks the patterns once flags are parsed.
+func (c *filterFlags) compile() error {
+	f, err := scan.NewFilter(c.spec())
+	c.compiled = f
+	return err
+}
+
+func (c filterFlags) spec() scan.FilterSpec {
+	spec := scan.FilterSpec{
+		Include:    c.include,
+		Exclude:    c.exclude,
+		IncludeExt: c.includeExt,
+		ExcludeExt: c.excludeExt,
+		MinSize:    c.minSize,
+		MaxSize:    c.maxSize,
+	}
+	now := time.Now()
+	if c.olderThan > 0 {
+		spec.ModifiedBefore = now.Add(-c.olderThan)
+	}
+	if c.newerThan > 0 {
+		spec.ModifiedAfter = now.Add(-c.newerThan)
+	}
+	return spec
+}
+
+// dated reports whether the age bounds are set, so listings should show modification times.
+func (c filterFlags) dated() bool {
+	return c.olderThan > 0 || c.newerThan > 0
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// openIndex loads the persistent index for root when --index is set.
+// The returned func writes it back; index problems only produce warnings.
+func (c *scanFlags) openIndex(root string) func() {
+	if !c.useIndex {
+		return func() {}
+	}
+	idx, err := scan.OpenIndex(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "index error: %v\n", err)
+		return func() {}
+	}
+	c.index = idx
+	return func() {
+		if err := idx.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "index error: %v\n", err)
+		}
+	}
+}
+
+// sizeNote spells out both size measures when hard links, sparse files or block rounding make them differ.
+func sizeNote(s scan.SizeTotals) string {
+	if s.Apparent == s.Allocated {
+		return ""
+	}
+	return fmt.Sprintf(", apparent: %s, on disk: %s", ui.HumanBytes(s.Apparent), ui.HumanBytes(s.Allocated))
+}
+
+// scanSummary holds the report summary fields every scan command shares.
+func scanSummary(total int64, sizes scan.SizeTotals, seen int, limited bool, errs scan.ScanErrors, links []scan.Symlink) []report.Field {
+	fields := report.SizeFields("total", total)
+	return append(fields,
+		report.Field{Key: "apparent_bytes", Value: sizes.Apparent},
+		report.Field{Key: "allocated_bytes", Value: sizes.Allocated},
+		report.Field{Key: "seen", Value: seen},
+		report.Field{Key: "limited", Value: limited},
+		report.Field{Key: "errors", Value: errs.Total()},
+		report.Field{Key: "symlinks", Value: len(links)},
+	)
+}
+
+// reportPathErrors prints the summary of paths a scan had to skip.
+func reportPathErrors(errs scan.ScanErrors) {
+	if errs.Total() == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s\n", errs.Summary())
+	for i, pe := range errs.Items {
+		if i == 3 {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "  %s (%s)\n", pe.Path, pe.Kind)
+	}
+}
+
+// printSymlinks lists the links met during a scan so skipped data stays visible.
+func printSymlinks(w io.Writer, root string, links []scan.Symlink) {
+	if len(links) == 0 {
+		return
+	}
+	followed := 0
+	for _, l := range links {
+		if l.Followed {
+			followed++
+		}
+	}
+	if followed == 0 && links[0].Reason == "not followed" {
+		fmt.Fprintf(w, "\nSYMLINKS: %d not followed (use --follow-symlinks to count them)\n", len(links))
+		return
+	}
+	fmt.Fprintf(w, "\nSYMLINKS: %d (%d followed)\n", len(links), followed)
+	for i, l := range links {
+		if i == 5 {
+			fmt.Fprintf(w, "  ... %d more\n", len(links)-i)
+			break
+		}
+		rel, err := filepath.Rel(root, l.Path)
+		if err != nil {
+			rel = l.Path
+		}
+		state := "followed"
+		if !l.Followed {
+			state = l.Reason
+		}
+		fmt.Fprintf(w, "  %s -> %s  [%s]\n", rel, l.Target, state)
+	}
 }
 
 func applyCommonFlags(c commonFlags) {
@@ -21,3 +289,18 @@ func applyCommonFlags(c commonFlags) {
 		color.NoColor = true
 	}
 }
+
+// interruptContext is cancelled on Ctrl+C so long scans can stop cleanly.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// reportScanError prints a scan failure and returns the process exit code for it.
+func reportScanError(err error) int {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "scan cancelled")
+		return 130
+	}
+	fmt.Fprintf(os.Stderr, "scan error: %v\n", err)
+	return 1
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"icicle/internal/dupes"
+	"icicle/internal/report"
+	"icicle/internal/ui"
+)
+
+func runDedupe(args []string) int {
+	fs := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	var common commonFlags
+	addCommonFlags(fs, &common)
+	var sf scanFlags
+	addScanFlags(fs, &sf)
+	var hf hashFlags
+	addHashFlags(fs, &hf)
+	methodName := fs.String("method", "auto", "auto, hardlink, reflink or delete")
+	var keepRules stringList
+	fs.Var(&keepRules, "keep", "which copy to keep: newest, oldest, shortest, longest, most-links, under:DIR, not-under:DIR, roots:A|B (repeatable, tried in order)")
+	apply := fs.Bool("apply", false, "replace or delete the duplicates; without it only the plan is printed")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	method, err := dupes.ParseMethod(*methodName)
+	var keep dupes.KeepPolicy
+	if err == nil {
+		keep, err = dupes.ParseKeepRules(strings.Join(keepRules, ";"))
+	}
+	if err != nil || fs.NArg() > 1 {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		fmt.Fprintln(os.Stderr, "usage: icicle dedupe [--method auto|hardlink|reflink|delete] [--keep RULE]... [--apply] [--min-size 1MB] [--hash-workers N] [--no-cache] [--workers N] [--max-files N] [--skip GLOB] [--one-file-system] [--include PAT] [--exclude PAT] [--include-ext .a,.b] [--exclude-ext .c] [--follow-symlinks] [--index] [--strict] [--format text|json|ndjson|csv|md] [--no-color] [--no-emoji] [path]")
+		return 2
+	}
+	if err := sf.filter.compile(); err != nil {
+		fmt.Fprintf(os.Stderr, "filter error: %v\n", err)
+		return 2
+	}
+	applyCommonFlags(common)
+
+	folders := detectUserFolders()
+	pathArg := fs.Arg(0)
+	if pathArg == "" {
+		pathArg = folders.Home
+	}
+	root, err := expandPath(pathArg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "path error: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	saveIndex := sf.openIndex(root)
+	defer saveIndex()
+	found, err := hf.find(ctx, root, sf)
+	if err != nil {
+		return reportScanError(err)
+	}
+	dryRun := !*apply
+	res, err := dupes.Dedupe(ctx, keep.Apply(found.Groups), dupes.DedupeOptions{Method: method, DryRun: dryRun})
+	// An interrupted run still reports the copies it already replaced.
+	if common.structured() {
+		if werr := writeDedupeReport(os.Stdout, common.format, root, keep, found, res, dryRun); werr != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", werr)
+			return 1
+		}
+	} else {
+		printDedupe(os.Stdout, root, method, keep, found, res, dryRun)
+	}
+	if err != nil {
+		return reportScanError(err)
+	}
+	if found.HashErrors > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d files could not be read while hashing\n", found.HashErrors)
+	}
+	reportPathErrors(found.Walk.Errors)
+	if res.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printDedupe(w io.Writer, root string, method dupes.Method, keep dupes.KeepPolicy, found *dupes.Result, res *dupes.DedupeResult, dryRun bool) {
+	mode := string(method) + ", keep " + keep.String()
+	if dryRun {
+		mode += ", dry-run"
+	}
+	fmt.Fprintf(w, "DEDUPE in %s  (%d groups, %s)\n", root, len(found.Groups), mode)
+	if found.Walk.Limited {
+		fmt.Fprintf(w, "(partial: stopped after %d files)\n", found.Walk.Seen)
+	}
+	if len(found.Groups) == 0 {
+		fmt.Fprintln(w, "No duplicates found.")
+		return
+	}
+	kept := ""
+	for _, r := range res.Replacements {
+		if r.Keep != kept {
+			kept = r.Keep
+			fmt.Fprintf(w, "\nkeep %s\n", kept)
+		}
+		switch r.Action {
+		case dupes.ActionDryRun, dupes.ActionReplaced:
+			fmt.Fprintf(w, "  %-8s %8s  %s\n", r.Method, ui.HumanBytes(r.Size), r.Path)
+		default:
+			fmt.Fprintf(w, "  %-8s %8s  %s (%v)\n", r.Action, ui.HumanBytes(r.Size), r.Path, r.Err)
+		}
+	}
+	verb := "Reclaimed"
+	if dryRun {
+		verb = "Would reclaim"
+	}
+	fmt.Fprintf(w, "\n%s %s  (%d replaced, %d skipped, %d failed)\n", verb, ui.HumanBytes(res.Reclaimed), res.Replaced, res.Skipped, res.Failed)
+	if dryRun && len(res.Replacements) > 0 {
+		fmt.Fprintln(w, "Nothing was changed. Run again with --apply to carry out this plan.")
+	}
+}
+
+func writeDedupeReport(w io.Writer, format report.Format, root string, keep dupes.KeepPolicy, found *dupes.Result, res *dupes.DedupeResult, dryRun bool) error {
+	enc := report.NewEncoder(w, format, "dedupe", root, report.DedupeColumns)
+	for _, r := range res.Replacements {
+		if err := enc.Row(report.DedupeRow(r.Action, string(r.Method), r.Path, r.Keep, r.Size, r.Reclaimed, r.Err)...); err != nil {
+			return err
+		}
+	}
+	summary := []report.Field{
+		{Key: "groups", Value: len(found.Groups)},
+		{Key: "dry_run", Value: dryRun},
+		{Key: "keep", Value: keep.String()},
+		{Key: "replaced", Value: res.Replaced},
+		{Key: "skipped", Value: res.Skipped},
+		{Key: "failed", Value: res.Failed},
+	}
+	summary = append(summary, report.SizeFields("reclaimed", res.Reclaimed)...)
+	summary = append(summary,
+		report.Field{Key: "hash_errors", Value: found.HashErrors},
+		report.Field{Key: "seen", Value: found.Walk.Seen},
+		report.Field{Key: "limited", Value: found.Walk.Limited},
+		report.Field{Key: "errors", Value: found.Walk.Errors.Total()},
+	)
+	return enc.Close(summary...)
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"icicle/internal/dupes"
+	"icicle/internal/report"
+	"icicle/internal/ui"
+)
+
+func runDirDupes(ctx context.Context, root string, sf scanFlags, hf hashFlags, common commonFlags, limit int) int {
+	// Every file has to count for two folders to be identical, so --min-size bounds the
+	// folder total here instead of filtering files.
+	minSize := sf.filter.minSize
+	sf.filter.minSize = 0
+	if err := sf.filter.compile(); err != nil {
+		fmt.Fprintf(os.Stderr, "filter error: %v\n", err)
+		return 2
+	}
+	if sf.filter.compiled != nil {
+		fmt.Fprintln(os.Stderr, "--dirs compares every file, so --include, --exclude, the extension lists and age or --max-size bounds cannot be used with it")
+		return 2
+	}
+	opts := dupes.DirOptions{Scan: sf.options(), MinSize: minSize, Workers: hf.workers}
+	if !hf.noCache {
+		if cache, err := dupes.OpenCache(); err != nil {
+			fmt.Fprintf(os.Stderr, "hash cache disabled: %v\n", err)
+		} else {
+			opts.Cache = cache
+			defer func() {
+				if err := cache.Save(); err != nil {
+					fmt.Fprintf(os.Stderr, "hash cache save error: %v\n", err)
+				}
+			}()
+		}
+	}
+	progress := newDupesProgress(os.Stderr)
+	opts.Progress = progress.update
+	res, err := dupes.FindDirs(ctx, root, opts)
+	progress.clear()
+	if err != nil {
+		return reportScanError(err)
+	}
+	if common.structured() {
+		if err := writeDirDupesReport(os.Stdout, common.format, res, limit); err != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+			return 1
+		}
+	} else {
+		printDirDupes(os.Stdout, res, limit)
+	}
+	if res.HashErrors > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d files could not be read while hashing\n", res.HashErrors)
+	}
+	reportPathErrors(res.Walk.Errors)
+	return 0
+}
+
+func shownDirGroups(groups []dupes.DirGroup, limit int) []dupes.DirGroup {
+	if limit > 0 && len(groups) > limit {
+		return groups[:limit]
+	}
+	return groups
+}
+
+func printDirDupes(w io.Writer, res *dupes.DirResult, limit int) {
+	fmt.Fprintf(w, "DUPLICATE FOLDERS in %s  (%d groups, %s wasted)\n", res.Root, len(res.Groups), ui.HumanBytes(res.Wasted))
+	if res.Walk.Limited {
+		fmt.Fprintf(w, "(partial: stopped after %d files)\n", res.Walk.Seen)
+	}
+	if res.CacheHits > 0 {
+		fmt.Fprintf(w, "(%d hashes reused from cache)\n", res.CacheHits)
+	}
+	if len(res.Groups) == 0 {
+		fmt.Fprintln(w, "No duplicate folders found.")
+		return
+	}
+	shown := shownDirGroups(res.Groups, limit)
+	for _, g := range shown {
+		fmt.Fprintf(w, "\n%8s x%d  (%d files each, %s wasted)  merkle:%s\n", ui.HumanBytes(g.Size), len(g.Dirs), g.Files, ui.HumanBytes(g.Wasted()), g.Hash[:12])
+		for _, d := range g.Dirs {
+			rel, err := filepath.Rel(res.Root, d)
+			if err != nil {
+				rel = d
+			}
+			fmt.Fprintf(w, "  %s%c\n", rel, filepath.Separator)
+		}
+	}
+	if rest := len(res.Groups) - len(shown); rest > 0 {
+		fmt.Fprintf(w, "\n... %d more groups\n", rest)
+	}
+}
+
+func writeDirDupesReport(w io.Writer, format report.Format, res *dupes.DirResult, limit int) error {
+	enc := report.NewEncoder(w, format, "dupe-dirs", res.Root, report.DirDupeColumns)
+	for i, g := range shownDirGroups(res.Groups, limit) {
+		for _, d := range g.Dirs {
+			if err := enc.Row(report.DirDupeRow(i+1, g.Hash, d, g.Size, g.Files)...); err != nil {
+				return err
+			}
+		}
+	}
+	summary := append([]report.Field{{Key: "groups", Value: len(res.Groups)}}, report.SizeFields("wasted", res.Wasted)...)
+	summary = append(summary,
+		report.Field{Key: "hashed_bytes", Value: res.HashedSize},
+		report.Field{Key: "hash_errors", Value: res.HashErrors},
+		report.Field{Key: "cache_hits", Value: res.CacheHits},
+		report.Field{Key: "seen", Value: res.Walk.Seen},
+		report.Field{Key: "limited", Value: res.Walk.Limited},
+		report.Field{Key: "errors", Value: res.Walk.Errors.Total()},
+	)
+	return enc.Close(summary...)
+}
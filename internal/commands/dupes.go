@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"icicle/internal/dupes"
+	"icicle/internal/report"
+	"icicle/internal/ui"
+)
+
+func runDupes(args []string) int {
+	fs := flag.NewFlagSet("dupes", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	var common commonFlags
+	addCommonFlags(fs, &common)
+	var sf scanFlags
+	addScanFlags(fs, &sf)
+	var hf hashFlags
+	addHashFlags(fs, &hf)
+	limit := fs.Int("n", 20, "number of duplicate groups to show (0 = all)")
+	similar := fs.Bool("similar", false, "find near-duplicate images (JPEG/PNG/GIF) and media instead of identical files")
+	distance := fs.Int("distance", dupes.DefaultMaxDistance, "with --similar: max differing bits (of 64) between image hashes")
+	dirs := fs.Bool("dirs", false, "find identical folder trees instead of single files")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 || (*similar && *dirs) {
+		fmt.Fprintln(os.Stderr, "usage: icicle dupes [--n 20] [--similar [--distance 10] | --dirs] [--min-size 1MB] [--hash-workers N] [--no-cache] [--workers N] [--max-files N] [--skip GLOB] [--one-file-system] [--include PAT] [--exclude PAT] [--include-ext .a,.b] [--exclude-ext .c] [--follow-symlinks] [--index] [--strict] [--format text|json|ndjson|csv|md] [--no-color] [--no-emoji] [path]")
+		return 2
+	}
+	if err := sf.filter.compile(); err != nil {
+		fmt.Fprintf(os.Stderr, "filter error: %v\n", err)
+		return 2
+	}
+	applyCommonFlags(common)
+
+	folders := detectUserFolders()
+	pathArg := fs.Arg(0)
+	if pathArg == "" {
+		pathArg = folders.Home
+	}
+	root, err := expandPath(pathArg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "path error: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	saveIndex := sf.openIndex(root)
+	defer saveIndex()
+	if *similar {
+		return runSimilar(ctx, root, sf, hf, common, *distance, *limit)
+	}
+	if *dirs {
+		return runDirDupes(ctx, root, sf, hf, common, *limit)
+	}
+	res, err := hf.find(ctx, root, sf)
+	if err != nil {
+		return reportScanError(err)
+	}
+	if common.structured() {
+		if err := writeDupesReport(os.Stdout, common.format, res, *limit); err != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+			return 1
+		}
+	} else {
+		printDupes(os.Stdout, res, *limit)
+	}
+	if res.HashErrors > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d files could not be read while hashing\n", res.HashErrors)
+	}
+	reportPathErrors(res.Walk.Errors)
+	return 0
+}
+
+// hashFlags tune content hashing for dupes and dedupe.
+type hashFlags struct {
+	workers int
+	noCache bool
+}
+
+func addHashFlags(fs *flag.FlagSet, h *hashFlags) {
+	fs.IntVar(&h.workers, "hash-workers", dupes.DefaultWorkers, "files hashed at once")
+	fs.BoolVar(&h.noCache, "no-cache", false, "do not read or update the hash cache")
+}
+
+// find searches root for duplicates with a progress line on stderr and the shared hash cache.
+func (h hashFlags) find(ctx context.Context, root string, sf scanFlags) (*dupes.Result, error) {
+	opts := dupes.Options{Scan: sf.options(), Workers: h.workers}
+	if !h.noCache {
+		cache, err := dupes.OpenCache()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hash cache disabled: %v\n", err)
+		} else {
+			opts.Cache = cache
+			defer func() {
+				if err := cache.Save(); err != nil {
+					fmt.Fprintf(os.Stderr, "hash cache save error: %v\n", err)
+				}
+			}()
+		}
+	}
+	progress := newDupesProgress(os.Stderr)
+	opts.Progress = progress.update
+	res, err := dupes.Find(ctx, root, opts)
+	progress.clear()
+	return res, err
+}
+
+func shownGroups(groups []dupes.Group, limit int) []dupes.Group {
+	if limit > 0 && len(groups) > limit {
+		return groups[:limit]
+	}
+	return groups
+}
+
+func printDupes(w io.Writer, res *dupes.Result, limit int) {
+	fmt.Fprintf(w, "DUPLICATES in %s  (%d groups, %s wasted)\n", res.Root, len(res.Groups), ui.HumanBytes(res.Wasted))
+	if res.Walk.Limited {
+		fmt.Fprintf(w, "(partial: stopped after %d files)\n", res.Walk.Seen)
+	}
+	if res.CacheHits > 0 {
+		fmt.Fprintf(w, "(%d hashes reused from cache)\n", res.CacheHits)
+	}
+	if len(res.Groups) == 0 {
+		fmt.Fprintln(w, "No duplicates found.")
+		return
+	}
+	shown := shownGroups(res.Groups, limit)
+	for _, g := range shown {
+		fmt.Fprintf(w, "\n%8s x%d  (%s wasted)  sha256:%s\n", ui.HumanBytes(g.Size), len(g.Files), ui.HumanBytes(g.Wasted()), g.Hash[:12])
+		for _, f := range g.Files {
+			rel, err := filepath.Rel(res.Root, f.Path)
+			if err != nil {
+				rel = f.Path
+			}
+			fmt.Fprintf(w, "  %s  %s\n", f.ModTime.Format("2006-01-02"), rel)
+		}
+	}
+	if rest := len(res.Groups) - len(shown); rest > 0 {
+		fmt.Fprintf(w, "\n... %d more groups\n", rest)
+	}
+}
+
+func writeDupesReport(w io.Writer, format report.Format, res *dupes.Result, limit int) error {
+	enc := report.NewEncoder(w, format, "dupes", res.Root, report.DupeColumns)
+	for i, g := range shownGroups(res.Groups, limit) {
+		for _, f := range g.Files {
+			if err := enc.Row(report.DupeRow(i+1, g.Hash, f.Path, f.Size, f.ModTime)...); err != nil {
+				return err
+			}
+		}
+	}
+	summary := append([]report.Field{{Key: "groups", Value: len(res.Groups)}}, report.SizeFields("wasted", res.Wasted)...)
+	summary = append(summary,
+		report.Field{Key: "hashed_bytes", Value: res.HashedSize},
+		report.Field{Key: "hash_errors", Value: res.HashErrors},
+		report.Field{Key: "cache_hits", Value: res.CacheHits},
+		report.Field{Key: "seen", Value: res.Walk.Seen},
+		report.Field{Key: "limited", Value: res.Walk.Limited},
+		report.Field{Key: "errors", Value: res.Walk.Errors.Total()},
+		report.Field{Key: "symlinks", Value: len(res.Walk.Symlinks)},
+	)
+	return enc.Close(summary...)
+}
+
+// dupesProgress redraws one status line on a terminal while duplicates are searched.
+type dupesProgress struct {
+	w    *os.File
+	tty  bool
+	last time.Time
+}
+
+func newDupesProgress(w *os.File) *dupesProgress {
+	return &dupesProgress{w: w, tty: isTerminal(w)}
+}
+
+func (p *dupesProgress) update(pr dupes.Progress) {
+	if !p.tty || time.Since(p.last) < 200*time.Millisecond {
+		return
+	}
+	p.last = time.Now()
+	switch pr.Stage {
+	case dupes.StageScan:
+		fmt.Fprintf(p.w, "\r\x1b[Kscanning... %d files", pr.Files)
+	case dupes.StageSimilar:
+		fmt.Fprintf(p.w, "\r\x1b[Kcomparing: %d/%d images and media files", pr.Hashed, pr.Candidates)
+	default:
+		fmt.Fprintf(p.w, "\r\x1b[K%s hash: %d/%d files, %s read", pr.Stage, pr.Hashed, pr.Candidates, ui.HumanBytes(pr.HashedSize))
+	}
+}
+
+func (p *dupesProgress) clear() {
+	if p.tty && !p.last.IsZero() {
+		fmt.Fprint(p.w, "\r\x1b[K")
+	}
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"icicle/internal/report"
+	"icicle/internal/scan"
+	"icicle/internal/ui"
+)
+
+func runExt(args []string) int {
+	fs := flag.NewFlagSet("ext", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	var common commonFlags
+	addCommonFlags(fs, &common)
+	var sf scanFlags
+	addScanFlags(fs, &sf)
+	limit := fs.Int("n", 20, "number of extensions to show")
+	width := fs.Int("w", 24, "bar width")
+	sortBy := fs.String("sort", "size", "order extensions by size or count")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 || (*sortBy != "size" && *sortBy != "count") {
+		fmt.Fprintln(os.Stderr, "usage: icicle ext [--n 20] [--w 24] [--sort size|count] [--workers N] [--max-files N] [--size apparent|allocated] [--skip GLOB] [--one-file-system] [--include PAT] [--exclude PAT] [--include-ext .a,.b] [--exclude-ext .c] [--follow-symlinks] [--index] [--strict] [--format text|json|ndjson|csv|md] [--no-color] [--no-emoji] [path]")
+		return 2
+	}
+	if err := sf.filter.compile(); err != nil {
+		fmt.Fprintf(os.Stderr, "filter error: %v\n", err)
+		return 2
+	}
+	applyCommonFlags(common)
+
+	folders := detectUserFolders()
+	pathArg := fs.Arg(0)
+	if pathArg == "" {
+		pathArg = folders.Home
+	}
+	root, err := expandPath(pathArg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "path error: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	saveIndex := sf.openIndex(root)
+	defer saveIndex()
+	stats, err := scan.ScanExtStatsContext(ctx, root, sf.options())
+	if err != nil {
+		return reportScanError(err)
+	}
+	view := extView{
+		items: stats.Items,
+		limit: *limit,
+		width: *width,
+		theme: ui.Theme{NoColor: common.noColor, NoEmoji: common.noEmoji},
+	}
+	if *sortBy == "count" {
+		sort.SliceStable(view.items, func(i, j int) bool {
+			return view.items[i].Count > view.items[j].Count
+		})
+	}
+	if common.structured() {
+		if err := view.writeReport(os.Stdout, common.format, stats); err != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+			return 1
+		}
+	} else {
+		view.print(os.Stdout, stats)
+	}
+	reportPathErrors(stats.Errors)
+	return 0
+}
+
+type extView struct {
+	items []scan.ExtStatsItem
+	limit int
+	width int
+	theme ui.Theme
+}
+
+func (v extView) shown() []scan.ExtStatsItem {
+	if v.limit > 0 && len(v.items) > v.limit {
+		return v.items[:v.limit]
+	}
+	return v.items
+}
+
+func (v extView) print(w io.Writer, stats *scan.ExtStats) {
+	size := stats.Total
+	fmt.Fprintf(w, "EXTENSIONS in %s  (%s in %d files%s)\n", stats.Root, ui.HumanBytes(size), stats.Seen, sizeNote(stats.Sizes))
+	if stats.Limited {
+		fmt.Fprintf(w, "(partial: stopped after %d files)\n", stats.Seen)
+	}
+	if len(v.items) == 0 {
+		fmt.Fprintln(w, "No files found.")
+		return
+	}
+	for _, it := range v.shown() {
+		ratio := 0.0
+		if size > 0 {
+			ratio = float64(it.Size) / float64(size)
+		}
+		fmt.Fprintf(w, "%-12s %8s %8d files  %s %5.1f%%\n", it.Ext, ui.HumanBytes(it.Size), it.Count, v.theme.Bar(ratio, v.width), ratio*100)
+	}
+	if rest := len(v.items) - len(v.shown()); rest > 0 {
+		fmt.Fprintf(w, "... %d more extensions\n", rest)
+	}
+	printSymlinks(w, stats.Root, stats.Symlinks)
+}
+
+func (v extView) writeReport(w io.Writer, format report.Format, stats *scan.ExtStats) error {
+	enc := report.NewEncoder(w, format, "ext", stats.Root, report.ExtColumns)
+	for _, it := range v.shown() {
+		if err := enc.Row(report.ExtRow(it.Ext, it.Count, it.Size, stats.Total)...); err != nil {
+			return err
+		}
+	}
+	summary := scanSummary(stats.Total, stats.Sizes, stats.Seen, stats.Limited, stats.Errors, stats.Symlinks)
+	return enc.Close(append(summary, report.Field{Key: "extensions", Value: len(v.items)})...)
+}
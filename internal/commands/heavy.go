@@ -3,9 +3,11 @@ package commands
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
+	"icicle/internal/report"
 	"icicle/internal/scan"
 	"icicle/internal/ui"
 )
@@ -15,12 +17,24 @@ func runHeavy(args []string) int {
 	fs.SetOutput(os.Stderr)
 	var common commonFlags
 	addCommonFlags(fs, &common)
+	var sf scanFlags
+	addScanFlags(fs, &sf)
 	limit := fs.Int("n", 20, "number of files to show")
+	live := fs.Bool("live", false, "redraw results while the scan runs")
+	fs.Func("sort", "rank files by size, mtime (oldest first) or age-weighted (size x days untouched)", func(v string) error {
+		order, err := scan.ParseTopOrder(v)
+		sf.topBy = order
+		return err
+	})
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
-	if fs.NArg() > 1 {
-		fmt.Fprintln(os.Stderr, "usage: icicle heavy [--n 20] [--no-color] [--no-emoji] [path]")
+	if fs.NArg() > 1 || (*live && common.structured()) {
+		fmt.Fprintln(os.Stderr, "usage: icicle heavy [--n 20] [--sort size|mtime|age-weighted] [--min-size 1GB] [--max-size SIZE] [--older-than 90d] [--newer-than 7d] [--workers N] [--max-files N] [--size apparent|allocated] [--skip GLOB] [--one-file-system] [--include PAT] [--exclude PAT] [--include-ext .a,.b] [--exclude-ext .c] [--follow-symlinks] [--index] [--strict] [--live] [--format text|json|ndjson|csv|md] [--no-color] [--no-emoji] [path]")
+		return 2
+	}
+	if err := sf.filter.compile(); err != nil {
+		fmt.Fprintf(os.Stderr, "filter error: %v\n", err)
 		return 2
 	}
 	applyCommonFlags(common)
@@ -36,24 +50,74 @@ func runHeavy(args []string) int {
 		return 1
 	}
 
-	stats, err := scan.ScanTopFiles(root, *limit)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "scan error: %v\n", err)
-		return 1
+	ctx, stop := interruptContext()
+	defer stop()
+	saveIndex := sf.openIndex(root)
+	defer saveIndex()
+	view := heavyView{noEmoji: common.noEmoji, dated: sf.topBy != scan.TopBySize || sf.filter.dated()}
+	var stats *scan.HeavyStats
+	if *live {
+		final, err := streamLive(ctx, root, sf.options(), scan.StreamOptions{TopFiles: *limit}, func(w io.Writer, p scan.Progress) {
+			view.print(w, root, p.HeavyStats())
+		})
+		if err != nil {
+			return reportScanError(err)
+		}
+		stats = final.HeavyStats()
+	} else {
+		stats, err = scan.ScanTopFilesContext(ctx, root, *limit, sf.options())
+		if err != nil {
+			return reportScanError(err)
+		}
+	}
+	if common.structured() {
+		if err := writeHeavyReport(os.Stdout, common.format, root, stats); err != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+			return 1
+		}
+	} else {
+		view.print(os.Stdout, root, stats)
 	}
+	reportPathErrors(stats.Errors)
+	return 0
+}
 
-	fmt.Printf("TOP FILES in %s\n", root)
+func writeHeavyReport(w io.Writer, format report.Format, root string, stats *scan.HeavyStats) error {
+	enc := report.NewEncoder(w, format, "heavy", root, report.HeavyColumns)
+	for _, f := range stats.TopFiles {
+		if err := enc.Row(report.HeavyRow(f.Path, f.Size, f.ModTime, f.AccessTime)...); err != nil {
+			return err
+		}
+	}
+	return enc.Close(scanSummary(stats.Total, stats.Sizes, stats.Seen, stats.Limited, stats.Errors, stats.Symlinks)...)
+}
+
+// heavyView prints the top files; dated adds the modification date to each line.
+type heavyView struct {
+	noEmoji bool
+	dated   bool
+}
+
+func (v heavyView) print(w io.Writer, root string, stats *scan.HeavyStats) {
+	fmt.Fprintf(w, "TOP FILES in %s\n", root)
+	if stats.Limited {
+		fmt.Fprintf(w, "(partial: stopped after %d files)\n", stats.Seen)
+	}
 	if len(stats.TopFiles) == 0 {
-		fmt.Println("No files found.")
-		return 0
+		fmt.Fprintln(w, "No files found.")
+		return
 	}
 	for _, file := range stats.TopFiles {
 		rel, relErr := filepath.Rel(root, file.Path)
 		if relErr != nil {
 			rel = file.Path
 		}
-		tag := fileEmoji(file.Size, common.noEmoji)
-		fmt.Printf("%s %8s  %s\n", tag, ui.HumanBytes(file.Size), rel)
+		tag := fileEmoji(file.Size, v.noEmoji)
+		if v.dated {
+			fmt.Fprintf(w, "%s %8s  %s  %s\n", tag, ui.HumanBytes(file.Size), file.ModTime.Format("2006-01-02"), rel)
+			continue
+		}
+		fmt.Fprintf(w, "%s %8s  %s\n", tag, ui.HumanBytes(file.Size), rel)
 	}
-	return 0
+	printSymlinks(w, root, stats.Symlinks)
 }
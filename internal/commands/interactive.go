@@ -55,15 +55,7 @@ func runInteractive() int {
 }
 
 func isInteractiveTerminal() bool {
-	stdinInfo, err := os.Stdin.Stat()
-	if err != nil {
-		return false
-	}
-	stdoutInfo, err := os.Stdout.Stat()
-	if err != nil {
-		return false
-	}
-	return (stdinInfo.Mode()&os.ModeCharDevice) != 0 && (stdoutInfo.Mode()&os.ModeCharDevice) != 0
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
 }
 
 func readLineOrDefault(r *bufio.Reader, fallback string) string {
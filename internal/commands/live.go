@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+
+	"icicle/internal/scan"
+	"icicle/internal/ui"
+)
+
+// streamLive consumes a streaming scan and redraws render on each partial update.
+// Redraws only happen when stdout is a terminal; piped output gets just the final result.
+func streamLive(ctx context.Context, root string, opts scan.Options, so scan.StreamOptions, render func(w io.Writer, p scan.Progress)) (scan.Progress, error) {
+	tty := isTerminal(os.Stdout)
+	var last scan.Progress
+	for p := range scan.Stream(ctx, root, opts, so) {
+		last = p
+		if p.Done || !tty {
+			continue
+		}
+		var b bytes.Buffer
+		b.WriteString("\x1b[H\x1b[2J")
+		fmt.Fprintf(&b, "scanning... %d files, %s, %.1fs (Ctrl+C to stop)\n\n", p.Seen, ui.HumanBytes(p.Total), p.Elapsed.Seconds())
+		render(&b, p)
+		_, _ = color.Output.Write(b.Bytes())
+	}
+	if tty {
+		_, _ = io.WriteString(color.Output, "\x1b[H\x1b[2J")
+	}
+	return last, last.Err
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -1,10 +1,12 @@
 package commands
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
 	"icicle/internal/meta"
+	"icicle/internal/report"
 )
 
 func Run(args []string) int {
@@ -20,13 +22,20 @@ func Run(args []string) int {
 		return 0
 	case "watch":
 		return runWatch(args[2:])
+	case "undo":
+		return runUndo(args[2:])
 	case "heavy":
 		return runHeavy(args[2:])
 	case "tree":
 		return runTree(args[2:])
+	case "ext":
+		return runExt(args[2:])
+	case "dupes":
+		return runDupes(args[2:])
+	case "dedupe":
+		return runDedupe(args[2:])
 	case "version", "-v", "--version":
-		fmt.Println("icicle " + meta.Version)
-		return 0
+		return runVersion(args[2:])
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", sub)
 		printRootUsage()
@@ -39,15 +48,42 @@ func printRootUsage() {
 	fmt.Println("")
 	fmt.Println("Usage:")
 	fmt.Println("  icicle watch [path]   Watch a folder and auto-sort new files")
+	fmt.Println("  icicle undo           Move files sorted by watch or the app back")
 	fmt.Println("  icicle heavy [path]   Show top largest files")
 	fmt.Println("  icicle tree [path]    Visualize size tree")
+	fmt.Println("  icicle ext [path]     Break down size by file extension")
+	fmt.Println("  icicle dupes [path]   Find files with identical content")
+	fmt.Println("  icicle dedupe [path]  Plan replacing duplicates with links; --apply carries it out")
 	fmt.Println("")
 	fmt.Println("Default paths:")
 	fmt.Println("  watch -> Windows Downloads folder")
-	fmt.Println("  heavy/tree -> Windows Home folder")
+	fmt.Println("  heavy/tree/ext/dupes/dedupe -> Windows Home folder")
 	fmt.Println("")
 	fmt.Println("Shared per-command flags:")
 	fmt.Println("  --no-color           Disable ANSI colors")
 	fmt.Println("  --no-emoji           Disable emoji in output")
+	fmt.Println("  --format FORMAT      text, json, ndjson, csv or md")
 	fmt.Println("")
 }
+
+func runVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	var common commonFlags
+	addCommonFlags(fs, &common)
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: icicle version [--format text|json|ndjson|csv|md]")
+		return 2
+	}
+	if !common.structured() {
+		fmt.Println("icicle " + meta.Version)
+		return 0
+	}
+	enc := report.NewEncoder(os.Stdout, common.format, "version", "", []string{"version"})
+	enc.Row(meta.Version)
+	if err := enc.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"icicle/internal/dupes"
+	"icicle/internal/report"
+	"icicle/internal/ui"
+)
+
+func runSimilar(ctx context.Context, root string, sf scanFlags, hf hashFlags, common commonFlags, distance, limit int) int {
+	progress := newDupesProgress(os.Stderr)
+	res, err := dupes.FindSimilar(ctx, root, dupes.SimilarOptions{
+		Scan:        sf.options(),
+		MaxDistance: distance,
+		Workers:     hf.workers,
+		Progress:    progress.update,
+	})
+	progress.clear()
+	if err != nil {
+		return reportScanError(err)
+	}
+	if common.structured() {
+		if err := writeSimilarReport(os.Stdout, common.format, res, limit); err != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+			return 1
+		}
+	} else {
+		printSimilar(os.Stdout, res, limit)
+	}
+	if res.Unreadable > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d images or media files could not be decoded\n", res.Unreadable)
+	}
+	reportPathErrors(res.Walk.Errors)
+	return 0
+}
+
+func shownSimilar(groups []dupes.SimilarGroup, limit int) []dupes.SimilarGroup {
+	if limit > 0 && len(groups) > limit {
+		return groups[:limit]
+	}
+	return groups
+}
+
+func printSimilar(w io.Writer, res *dupes.SimilarResult, limit int) {
+	var reclaimable int64
+	for _, g := range res.Groups {
+		reclaimable += g.Reclaimable()
+	}
+	fmt.Fprintf(w, "SIMILAR in %s  (%d groups of %d compared files, %s reclaimable)\n", res.Root, len(res.Groups), res.Compared, ui.HumanBytes(reclaimable))
+	if res.Walk.Limited {
+		fmt.Fprintf(w, "(partial: stopped after %d files)\n", res.Walk.Seen)
+	}
+	if len(res.Groups) == 0 {
+		fmt.Fprintln(w, "No similar files found.")
+		return
+	}
+	shown := shownSimilar(res.Groups, limit)
+	for _, g := range shown {
+		fmt.Fprintf(w, "\n%s x%d  (%s reclaimable, distance <= %d)\n", g.Kind, len(g.Files), ui.HumanBytes(g.Reclaimable()), g.MaxDistance)
+		for _, f := range g.Files {
+			rel, err := filepath.Rel(res.Root, f.Path)
+			if err != nil {
+				rel = f.Path
+			}
+			detail := f.Duration.Round(time.Second).String()
+			if g.Kind == dupes.KindImage {
+				detail = fmt.Sprintf("%dx%d", f.Width, f.Height)
+			}
+			fmt.Fprintf(w, "  %3d  %8s  %-10s %s\n", f.Distance, ui.HumanBytes(f.Size), detail, rel)
+		}
+	}
+	if rest := len(res.Groups) - len(shown); rest > 0 {
+		fmt.Fprintf(w, "\n... %d more groups\n", rest)
+	}
+}
+
+func writeSimilarReport(w io.Writer, format report.Format, res *dupes.SimilarResult, limit int) error {
+	enc := report.NewEncoder(w, format, "similar", res.Root, report.SimilarColumns)
+	var reclaimable int64
+	for _, g := range res.Groups {
+		reclaimable += g.Reclaimable()
+	}
+	for i, g := range shownSimilar(res.Groups, limit) {
+		for _, f := range g.Files {
+			if err := enc.Row(report.SimilarRow(i+1, g.Kind, f.Distance, f.Path, f.Size, f.Width, f.Height, f.Duration)...); err != nil {
+				return err
+			}
+		}
+	}
+	summary := []report.Field{
+		{Key: "groups", Value: len(res.Groups)},
+		{Key: "compared", Value: res.Compared},
+		{Key: "unreadable", Value: res.Unreadable},
+	}
+	summary = append(summary, report.SizeFields("reclaimable", reclaimable)...)
+	summary = append(summary,
+		report.Field{Key: "seen", Value: res.Walk.Seen},
+		report.Field{Key: "limited", Value: res.Walk.Limited},
+		report.Field{Key: "errors", Value: res.Walk.Errors.Total()},
+	)
+	return enc.Close(summary...)
+}
@@ -3,9 +3,11 @@ package commands
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
+	"icicle/internal/report"
 	"icicle/internal/scan"
 	"icicle/internal/ui"
 )
@@ -15,14 +17,22 @@ func runTree(args []string) int {
 	fs.SetOutput(os.Stderr)
 	var common commonFlags
 	addCommonFlags(fs, &common)
+	var sf scanFlags
+	addScanFlags(fs, &sf)
 	limit := fs.Int("n", 20, "number of child entries to show")
 	width := fs.Int("w", 24, "bar width")
 	top := fs.Int("top", 5, "show top N files under tree")
+	live := fs.Bool("live", false, "redraw results while the scan runs")
+	depth := fs.Int("depth", 0, "show nested folders down to N levels (0 = first level only)")
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
-	if fs.NArg() > 1 {
-		fmt.Fprintln(os.Stderr, "usage: icicle tree [--n 20] [--w 24] [--top 5] [--no-color] [--no-emoji] [path]")
+	if fs.NArg() > 1 || (*live && (*depth > 0 || common.structured())) {
+		fmt.Fprintln(os.Stderr, "usage: icicle tree [--n 20] [--w 24] [--top 5] [--workers N] [--max-files N] [--depth N] [--size apparent|allocated] [--skip GLOB] [--one-file-system] [--include PAT] [--exclude PAT] [--include-ext .a,.b] [--exclude-ext .c] [--follow-symlinks] [--index] [--strict] [--live] [--format text|json|ndjson|csv|md] [--no-color] [--no-emoji] [path]")
+		return 2
+	}
+	if err := sf.filter.compile(); err != nil {
+		fmt.Fprintf(os.Stderr, "filter error: %v\n", err)
 		return 2
 	}
 	applyCommonFlags(common)
@@ -38,22 +48,73 @@ func runTree(args []string) int {
 		return 1
 	}
 
-	stats, err := scan.ScanTree(root, *top)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "scan error: %v\n", err)
-		return 1
+	ctx, stop := interruptContext()
+	defer stop()
+	saveIndex := sf.openIndex(root)
+	defer saveIndex()
+	theme := ui.Theme{NoColor: common.noColor, NoEmoji: common.noEmoji}
+	view := treeView{limit: *limit, width: *width, theme: theme}
+	if *depth > 0 || common.structured() {
+		tree, err := scan.ScanDirTreeContext(ctx, root, *top, sf.options())
+		if err != nil {
+			return reportScanError(err)
+		}
+		if common.structured() {
+			if err := view.writeReport(os.Stdout, common.format, tree, *depth); err != nil {
+				fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+				return 1
+			}
+		} else {
+			view.printDeep(os.Stdout, tree, *depth)
+		}
+		reportPathErrors(tree.Errors)
+		return 0
 	}
+	var stats *scan.TreeStats
+	if *live {
+		final, err := streamLive(ctx, root, sf.options(), scan.StreamOptions{TopFiles: *top}, func(w io.Writer, p scan.Progress) {
+			view.print(w, root, p.TreeStats())
+		})
+		if err != nil {
+			return reportScanError(err)
+		}
+		stats = final.TreeStats()
+	} else {
+		stats, err = scan.ScanTreeContext(ctx, root, *top, sf.options())
+		if err != nil {
+			return reportScanError(err)
+		}
+	}
+	view.print(os.Stdout, root, stats)
+	reportPathErrors(stats.Errors)
 
-	theme := ui.Theme{NoColor: common.noColor, NoEmoji: common.noEmoji}
-	fmt.Printf("%s  (total: %s)\n", root, ui.HumanBytes(stats.Total))
+	// Tiny easter egg for huge folders.
+	if stats.Total >= 500*1024*1024*1024 {
+		fmt.Println("\nice alert: this path is glacier-class heavy")
+	}
+
+	return 0
+}
+
+type treeView struct {
+	limit int
+	width int
+	theme ui.Theme
+}
+
+func (v treeView) print(w io.Writer, root string, stats *scan.TreeStats) {
+	fmt.Fprintf(w, "%s  (total: %s%s)\n", root, ui.HumanBytes(stats.Total), sizeNote(stats.Sizes))
+	if stats.Limited {
+		fmt.Fprintf(w, "(partial: stopped after %d files)\n", stats.Seen)
+	}
 
 	shown := 0
 	childCount := len(stats.ChildNames)
-	if childCount > *limit {
-		childCount = *limit
+	if childCount > v.limit {
+		childCount = v.limit
 	}
 	for _, name := range stats.ChildNames {
-		if shown >= *limit {
+		if shown >= v.limit {
 			break
 		}
 		size := stats.ByChild[name]
@@ -66,7 +127,7 @@ func runTree(args []string) int {
 		if isLastChild && stats.RootFiles == 0 {
 			prefix = "`-"
 		}
-		fmt.Printf("%s %s %-20s %8s  %s\n", prefix, "[DIR]", name, ui.HumanBytes(size), theme.Bar(ratio, *width))
+		fmt.Fprintf(w, "%s %s %-20s %8s  %s\n", prefix, "[DIR]", name, ui.HumanBytes(size), v.theme.Bar(ratio, v.width))
 		shown++
 	}
 	if stats.RootFiles > 0 {
@@ -74,24 +135,100 @@ func runTree(args []string) int {
 		if stats.Total > 0 {
 			ratio = float64(stats.RootFiles) / float64(stats.Total)
 		}
-		fmt.Printf("`- [FILES] %-18s %8s  %s\n", "(root)", ui.HumanBytes(stats.RootFiles), theme.Bar(ratio, *width))
+		fmt.Fprintf(w, "`- [FILES] %-18s %8s  %s\n", "(root)", ui.HumanBytes(stats.RootFiles), v.theme.Bar(ratio, v.width))
 	}
 
-	fmt.Println()
-	fmt.Println("TOP FILES:")
-	for _, file := range stats.TopFiles {
-		rel, relErr := filepath.Rel(root, file.Path)
-		if relErr != nil {
-			rel = file.Path
+	v.printTopFiles(w, root, stats.TopFiles)
+	printSymlinks(w, root, stats.Symlinks)
+}
+
+// printDeep renders a DirTree down to depth levels; bars stay relative to the root total.
+func (v treeView) printDeep(w io.Writer, tree *scan.DirTree, depth int) {
+	root := tree.Root
+	fmt.Fprintf(w, "%s  (total: %s, %d files, %d dirs%s)\n", root.Name, ui.HumanBytes(root.Size), root.Files, root.Dirs, sizeNote(tree.Sizes))
+	if tree.Limited {
+		fmt.Fprintf(w, "(partial: stopped after %d files)\n", tree.Seen)
+	}
+	v.printNodes(w, root, root.Size, "", depth)
+	if root.Own > 0 {
+		ratio := 0.0
+		if root.Size > 0 {
+			ratio = float64(root.Own) / float64(root.Size)
 		}
-		tag := fileEmoji(file.Size, common.noEmoji)
-		fmt.Printf("%s %8s  %s\n", tag, ui.HumanBytes(file.Size), rel)
+		fmt.Fprintf(w, "%-32s %8s  %s\n", "`- (root files)", ui.HumanBytes(root.Own), v.theme.Bar(ratio, v.width))
 	}
+	v.printTopFiles(w, root.Name, tree.TopFiles)
+	printSymlinks(w, root.Name, tree.Symlinks)
+}
 
-	// Tiny easter egg for huge folders.
-	if stats.Total >= 500*1024*1024*1024 {
-		fmt.Println("\nice alert: this path is glacier-class heavy")
+// writeReport emits one row per folder down to depth (at least one level), largest first per level.
+func (v treeView) writeReport(w io.Writer, format report.Format, tree *scan.DirTree, depth int) error {
+	if depth < 1 {
+		depth = 1
 	}
+	enc := report.NewEncoder(w, format, "tree", tree.Root.Name, report.TreeColumns)
+	var walk func(n *scan.DirNode, level int) error
+	walk = func(n *scan.DirNode, level int) error {
+		children := n.Children
+		if len(children) > v.limit {
+			children = children[:v.limit]
+		}
+		for _, c := range children {
+			if err := enc.Row(c.Path(), c.Name, level, c.Size, ui.HumanBytes(c.Size), c.Files, c.Dirs); err != nil {
+				return err
+			}
+			if level < depth {
+				if err := walk(c, level+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(tree.Root, 1); err != nil {
+		return err
+	}
+	root := tree.Root
+	summary := scanSummary(root.Size, tree.Sizes, tree.Seen, tree.Limited, tree.Errors, tree.Symlinks)
+	summary = append(summary,
+		report.Field{Key: "files", Value: root.Files},
+		report.Field{Key: "dirs", Value: root.Dirs},
+		report.Field{Key: "root_files_bytes", Value: root.Own},
+	)
+	return enc.Close(summary...)
+}
 
-	return 0
+func (v treeView) printNodes(w io.Writer, n *scan.DirNode, total int64, indent string, depth int) {
+	children := n.Children
+	if len(children) > v.limit {
+		children = children[:v.limit]
+	}
+	for i, c := range children {
+		ratio := 0.0
+		if total > 0 {
+			ratio = float64(c.Size) / float64(total)
+		}
+		prefix, next := "|-", indent+"|  "
+		if i == len(children)-1 && (indent != "" || n.Own == 0) {
+			prefix, next = "`-", indent+"   "
+		}
+		name := indent + prefix + " " + c.Name
+		fmt.Fprintf(w, "%-32s %8s  %s\n", name, ui.HumanBytes(c.Size), v.theme.Bar(ratio, v.width))
+		if depth > 1 {
+			v.printNodes(w, c, total, next, depth-1)
+		}
+	}
+}
+
+func (v treeView) printTopFiles(w io.Writer, root string, files []scan.FileInfo) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "TOP FILES:")
+	for _, file := range files {
+		rel, relErr := filepath.Rel(root, file.Path)
+		if relErr != nil {
+			rel = file.Path
+		}
+		tag := fileEmoji(file.Size, v.theme.NoEmoji)
+		fmt.Fprintf(w, "%s %8s  %s\n", tag, ui.HumanBytes(file.Size), rel)
+	}
 }
@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"icicle/internal/organize"
+	"icicle/internal/report"
+)
+
+func runUndo(args []string) int {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	var common commonFlags
+	addCommonFlags(fs, &common)
+	last := fs.Int("last", 0, "undo the N most recent moves (default 1)")
+	since := fs.Duration("since", 0, "undo every move made within this long, e.g. 1h")
+	id := fs.String("id", "", "undo the move with this journal id")
+	list := fs.Bool("list", false, "list the moves that can be undone instead of undoing them")
+	dryRun := fs.Bool("dry-run", false, "print what would be restored without moving files")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	selectors := 0
+	for _, set := range []bool{*last != 0, *since != 0, *id != ""} {
+		if set {
+			selectors++
+		}
+	}
+	if fs.NArg() > 0 || selectors > 1 || *last < 0 || *since < 0 {
+		fmt.Fprintln(os.Stderr, "usage: icicle undo [--last N | --since 1h | --id ID] [--list] [--dry-run] [--format text|json|ndjson|csv|md] [--no-color] [--no-emoji]")
+		return 2
+	}
+	if selectors == 0 && !*list {
+		*last = 1
+	}
+	applyCommonFlags(common)
+
+	journal, err := organize.OpenJournal()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "journal error: %v\n", err)
+		return 1
+	}
+	pending, err := journal.Pending()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "journal error: %v\n", err)
+		return 1
+	}
+	moves, err := selectMoves(pending, *last, *since, *id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var enc *report.Encoder
+	if common.structured() {
+		enc = report.NewEncoder(os.Stdout, common.format, "undo", journal.Path(), report.UndoColumns)
+	}
+	if *list {
+		for _, m := range moves {
+			if enc != nil {
+				if err := enc.Row(undoRow(m, "pending", m.From, m.To, nil)...); err != nil {
+					fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+					return 1
+				}
+				continue
+			}
+			fmt.Printf("%s  %s  %-5s  %s -> %s\n", m.ID, m.Time.Local().Format("2006-01-02 15:04:05"), m.Source, m.From, m.To)
+		}
+		if enc != nil {
+			return closeReport(enc, report.Field{Key: "pending", Value: len(moves)})
+		}
+		if len(moves) == 0 {
+			fmt.Println("nothing to undo")
+		}
+		return 0
+	}
+
+	// Newest first, so a file moved twice goes back one step at a time.
+	restored, failed := 0, 0
+	for i := len(moves) - 1; i >= 0; i-- {
+		m := moves[i]
+		u, err := journal.Undo(m, organize.SourceCLI, *dryRun)
+		action := "restored"
+		switch {
+		case err != nil:
+			action = "failed"
+			failed++
+			u.From, u.To = m.To, m.From
+		case *dryRun:
+			action = "dry-run"
+		default:
+			restored++
+		}
+		if enc != nil {
+			if werr := enc.Row(undoRow(m, action, u.From, u.To, err)...); werr != nil {
+				fmt.Fprintf(os.Stderr, "output error: %v\n", werr)
+				return 1
+			}
+			continue
+		}
+		fmt.Println(undoLine(m, u, action, err))
+	}
+	if enc != nil {
+		if code := closeReport(enc,
+			report.Field{Key: "selected", Value: len(moves)},
+			report.Field{Key: "dry_run", Value: *dryRun},
+			report.Field{Key: "restored", Value: restored},
+			report.Field{Key: "failed", Value: failed},
+		); code != 0 {
+			return code
+		}
+	} else if len(moves) == 0 {
+		fmt.Println("nothing to undo")
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// selectMoves picks the pending moves to act on, oldest first.
+func selectMoves(pending []organize.JournalEntry, last int, since time.Duration, id string) ([]organize.JournalEntry, error) {
+	switch {
+	case id != "":
+		for _, m := range pending {
+			if m.ID == id {
+				return []organize.JournalEntry{m}, nil
+			}
+		}
+		return nil, fmt.Errorf("no move %s that can be undone (see icicle undo --list)", id)
+	case since > 0:
+		cutoff := time.Now().Add(-since)
+		for i, m := range pending {
+			if !m.Time.Before(cutoff) {
+				return pending[i:], nil
+			}
+		}
+		return nil, nil
+	case last > 0 && last < len(pending):
+		return pending[len(pending)-last:], nil
+	}
+	return pending, nil
+}
+
+func undoLine(m, u organize.JournalEntry, action string, err error) string {
+	if err != nil {
+		return fmt.Sprintf("undo failed %s %s (%v)", m.ID, m.To, err)
+	}
+	line := fmt.Sprintf("restored %s -> %s", u.From, u.To)
+	if action == "dry-run" {
+		line = fmt.Sprintf("[dry-run] %s -> %s", u.From, u.To)
+	}
+	if u.From != m.To {
+		line += "  (found renamed)"
+	}
+	if u.To != m.From {
+		line += fmt.Sprintf("  (%s is taken)", m.From)
+	}
+	return line
+}
+
+func undoRow(m organize.JournalEntry, action, from, to string, err error) []any {
+	errText := ""
+	if err != nil {
+		errText = err.Error()
+	}
+	return []any{m.ID, report.Time(m.Time), action, m.Source, from, to, errText}
+}
+
+func closeReport(enc *report.Encoder, summary ...report.Field) int {
+	if err := enc.Close(summary...); err != nil {
+		fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -7,12 +7,16 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 
 	"icicle/internal/organize"
+	"icicle/internal/report"
+	"icicle/internal/scan"
+	"icicle/internal/skip"
 )
 
 func runWatch(args []string) int {
@@ -21,11 +25,25 @@ func runWatch(args []string) int {
 	var common commonFlags
 	addCommonFlags(fs, &common)
 	dryRun := fs.Bool("dry-run", false, "print actions without moving files")
+	rulesFile := fs.String("rules", "", "routing rules file (default: routing_rules.json in the icicle config folder)")
+	quiet := fs.Duration("quiet-period", 3*time.Second, "move a file only after its size and time have not changed for this long")
+	var sk skipFlags
+	addSkipFlags(fs, &sk)
+	var ff filterFlags
+	addFilterFlags(fs, &ff)
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
 	if fs.NArg() > 1 {
-		fmt.Fprintln(os.Stderr, "usage: icicle watch [--dry-run] [--no-color] [--no-emoji] [path]")
+		fmt.Fprintln(os.Stderr, "usage: icicle watch [--dry-run] [--rules FILE] [--quiet-period 3s] [--skip GLOB] [--one-file-system] [--include PAT] [--exclude PAT] [--include-ext .a,.b] [--exclude-ext .c] [--format text|json|ndjson|csv|md] [--no-color] [--no-emoji] [path]")
+		return 2
+	}
+	if err := ff.compile(); err != nil {
+		fmt.Fprintf(os.Stderr, "filter error: %v\n", err)
+		return 2
+	}
+	if *quiet < 0 {
+		fmt.Fprintln(os.Stderr, "--quiet-period must not be negative")
 		return 2
 	}
 	applyCommonFlags(common)
@@ -41,6 +59,17 @@ func runWatch(args []string) int {
 		return 1
 	}
 	home := folders.Home
+	rules, err := openWatchRules(*rulesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rules error: %v\n", err)
+		return 1
+	}
+	var journal *organize.Journal
+	if !*dryRun {
+		if journal, err = organize.OpenJournal(); err != nil {
+			fmt.Fprintf(os.Stderr, "journal error (moves cannot be undone): %v\n", err)
+		}
+	}
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -49,21 +78,46 @@ func runWatch(args []string) int {
 	}
 	defer watcher.Close()
 
-	if err := addRecursiveWatches(watcher, watchRoot); err != nil {
+	skipDirs := sk.policy().Bind(watchRoot)
+	if err := addRecursiveWatches(watcher, watchRoot, skipDirs); err != nil {
 		fmt.Fprintf(os.Stderr, "watch add error: %v\n", err)
 		return 1
 	}
 
-	fmt.Printf("watching %s\n", watchRoot)
-	fmt.Printf("sorting destination base: %s\n", home)
+	// Structured output keeps stdout machine-readable; the banner goes to stderr.
+	banner := os.Stdout
+	var enc *report.Encoder
+	if common.structured() {
+		banner = os.Stderr
+		enc = report.NewEncoder(os.Stdout, common.format, "watch", watchRoot, report.MoveColumns)
+		defer enc.Close()
+	}
+	fmt.Fprintf(banner, "watching %s\n", watchRoot)
+	fmt.Fprintf(banner, "sorting destination base: %s\n", home)
+	fmt.Fprintf(banner, "routing rules: %d from %s\n", len(rules.rules), rules.path)
+	fmt.Fprintf(banner, "moving files once unchanged for %s\n", *quiet)
 	if *dryRun {
-		fmt.Println("dry-run enabled")
+		fmt.Fprintln(banner, "dry-run enabled")
 	}
-	fmt.Println("press Ctrl+C to stop")
+	fmt.Fprintln(banner, "press Ctrl+C to stop")
 
-	cooldown := map[string]time.Time{}
+	emit := func(ev moveEvent) error {
+		if enc != nil {
+			return enc.Row(ev.row()...)
+		}
+		fmt.Println(ev.String())
+		return nil
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	pending := newPendingFiles(*quiet)
+	ticker := time.NewTicker(pending.interval())
+	defer ticker.Stop()
 	for {
 		select {
+		case <-ctx.Done():
+			return 0
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return 0
@@ -72,18 +126,30 @@ func runWatch(args []string) int {
 				continue
 			}
 
-			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
-				_ = addRecursiveWatches(watcher, event.Name)
+			info, err := os.Stat(event.Name)
+			if err != nil {
 				continue
 			}
-
-			if shouldSkipEvent(event.Name, cooldown) {
+			if info.IsDir() {
+				if !skipDirs.SkipDir(event.Name, info.Name()) {
+					_ = addRecursiveWatches(watcher, event.Name, skipDirs)
+				}
 				continue
 			}
-
-			handled, msg := maybeMoveFile(home, event.Name, *dryRun)
-			if handled {
-				fmt.Println(msg)
+			if organize.IsPartialDownload(event.Name) {
+				continue
+			}
+			pending.touch(event.Name, info, time.Now())
+		case now := <-ticker.C:
+			for _, path := range pending.settled(now) {
+				ev, handled := maybeMoveFile(home, path, ff.compiled, rules.current(), journal, *dryRun)
+				if !handled {
+					continue
+				}
+				if err := emit(ev); err != nil {
+					fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+					return 1
+				}
 			}
 		case err, ok := <-watcher.Errors:
 			if !ok {
@@ -94,7 +160,7 @@ func runWatch(args []string) int {
 	}
 }
 
-func addRecursiveWatches(w *fsnotify.Watcher, root string) error {
+func addRecursiveWatches(w *fsnotify.Watcher, root string, skipDirs *skip.Matcher) error {
 	warnCount := 0
 	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -106,7 +172,7 @@ func addRecursiveWatches(w *fsnotify.Watcher, root string) error {
 			}
 			return err
 		}
-		if shouldSkipWatchDir(d) {
+		if d.IsDir() && path != root && skipDirs.SkipDir(path, d.Name()) {
 			return filepath.SkipDir
 		}
 		if d.Type()&os.ModeSymlink != 0 {
@@ -135,18 +201,6 @@ func addRecursiveWatches(w *fsnotify.Watcher, root string) error {
 	})
 }
 
-func shouldSkipWatchDir(d os.DirEntry) bool {
-	if d == nil || !d.IsDir() {
-		return false
-	}
-	name := strings.ToLower(d.Name())
-	return name == "$recycle.bin" ||
-		name == "system volume information" ||
-		name == "$extend" ||
-		name == "windowsapps" ||
-		name == "msocache"
-}
-
 func isWatchAccessDenied(err error) bool {
 	if os.IsPermission(err) {
 		return true
@@ -164,67 +218,215 @@ func isWatchAccessDenied(err error) bool {
 	return strings.Contains(msg, "access is denied") || strings.Contains(msg, "permission denied")
 }
 
-func shouldSkipEvent(path string, cooldown map[string]time.Time) bool {
-	// Пасхалка: "блять, я не знаю как это работает, пусть будет" :)
-	// По факту это простой антидребезг, чтобы не ловить один и тот же файл по 10 раз.
-	now := time.Now()
-	last, ok := cooldown[path]
-	if ok && now.Sub(last) < 2*time.Second {
-		return true
+// pendingFiles holds changed files until they settle: the same size and modification time
+// for the quiet period, no sibling download file, and no other process writing to them.
+type pendingFiles struct {
+	quiet time.Duration
+	files map[string]*pendingFile
+}
+
+type pendingFile struct {
+	size  int64
+	mod   time.Time
+	since time.Time
+}
+
+func newPendingFiles(quiet time.Duration) *pendingFiles {
+	return &pendingFiles{quiet: quiet, files: map[string]*pendingFile{}}
+}
+
+// interval is how often settled polls.
+func (p *pendingFiles) interval() time.Duration {
+	return min(max(p.quiet/4, 100*time.Millisecond), time.Second)
+}
+
+// touch records a change to path; its quiet period starts over.
+func (p *pendingFiles) touch(path string, info os.FileInfo, now time.Time) {
+	p.files[path] = &pendingFile{size: info.Size(), mod: info.ModTime(), since: now}
+}
+
+// settled returns the files that are ready to move and forgets them. Files that are gone
+// are dropped.
+func (p *pendingFiles) settled(now time.Time) []string {
+	var ready []string
+	for path, f := range p.files {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			delete(p.files, path)
+			continue
+		}
+		if info.Size() != f.size || !info.ModTime().Equal(f.mod) {
+			p.touch(path, info, now)
+			continue
+		}
+		if now.Sub(f.since) < p.quiet {
+			continue
+		}
+		if organize.IsPartialDownload(path) || organize.InUse(path) {
+			f.since = now
+			continue
+		}
+		delete(p.files, path)
+		ready = append(ready, path)
 	}
-	cooldown[path] = now
-	if len(cooldown) > 4096 {
-		for k, v := range cooldown {
-			if now.Sub(v) > 15*time.Second {
-				delete(cooldown, k)
-			}
+	sort.Strings(ready)
+	return ready
+}
+
+// moveEvent is the outcome of one watcher decision.
+type moveEvent struct {
+	At     time.Time
+	Action string // moved, dry-run, skip or failed
+	Src    string
+	Dst    string
+	Size   int64
+	Rule   string
+	// ID is the journal entry of a completed move.
+	ID  string
+	Err error
+}
+
+func (e moveEvent) String() string {
+	switch e.Action {
+	case "skip":
+		return fmt.Sprintf("skip %s (%v)", e.Src, e.Err)
+	case "dry-run":
+		return fmt.Sprintf("[dry-run] %s -> %s", e.Src, e.Dst)
+	case "failed":
+		return fmt.Sprintf("move failed %s (%v)", e.Src, e.Err)
+	}
+	if e.Size > 4*1024*1024*1024 {
+		// Easter egg: exceptionally large drops get a special line.
+		return fmt.Sprintf("moved %s -> %s  [black-ice payload]", e.Src, e.Dst)
+	}
+	return fmt.Sprintf("moved %s -> %s", e.Src, e.Dst)
+}
+
+func (e moveEvent) row() []any {
+	errText := ""
+	if e.Err != nil {
+		errText = e.Err.Error()
+	}
+	return []any{report.Time(e.At), e.Action, e.Src, e.Dst, e.Size, errText, e.Rule, e.ID}
+}
+
+// watchRules keeps the routing rules in step with their file, so edits saved by the
+// desktop app apply without restarting the watcher.
+type watchRules struct {
+	path  string
+	mod   time.Time
+	rules []organize.RouteRule
+}
+
+func openWatchRules(path string) (*watchRules, error) {
+	if path == "" {
+		var err error
+		if path, err = organize.RouteRulesPath(); err != nil {
+			return nil, err
 		}
+	} else {
+		abs, err := expandPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, err
+		}
+		path = abs
+	}
+	w := &watchRules{path: path}
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *watchRules) load() error {
+	w.mod = time.Time{}
+	if info, err := os.Stat(w.path); err == nil {
+		w.mod = info.ModTime()
 	}
-	return false
+	rules, err := organize.LoadRouteRules(w.path)
+	if err != nil {
+		return err
+	}
+	organize.SortRouteRules(rules)
+	w.rules = rules
+	return nil
 }
 
-func maybeMoveFile(home, srcPath string, dryRun bool) (bool, string) {
+// current reloads the rules when their file changed. A file that no longer parses keeps
+// the previous rules.
+func (w *watchRules) current() []organize.RouteRule {
+	var mod time.Time
+	if info, err := os.Stat(w.path); err == nil {
+		mod = info.ModTime()
+	}
+	if mod.Equal(w.mod) {
+		return w.rules
+	}
+	if err := w.load(); err != nil {
+		w.mod = mod
+		fmt.Fprintf(os.Stderr, "rules error (keeping %d loaded rules): %v\n", len(w.rules), err)
+		return w.rules
+	}
+	fmt.Fprintf(os.Stderr, "routing rules reloaded: %d\n", len(w.rules))
+	return w.rules
+}
+
+func maybeMoveFile(home, srcPath string, filter *scan.Filter, rules []organize.RouteRule, journal *organize.Journal, dryRun bool) (moveEvent, bool) {
 	info, err := os.Stat(srcPath)
 	if err != nil || info.IsDir() {
-		return false, ""
+		return moveEvent{}, false
+	}
+	if !filter.Match(srcPath, info.Size(), info.ModTime()) {
+		return moveEvent{}, false
 	}
 
-	dstDir, ok := organize.DestinationDir(home, srcPath)
+	dstDir, rule, ok := organize.Route(rules, home, srcPath)
 	if !ok {
-		return false, ""
+		return moveEvent{}, false
 	}
 
 	srcAbs, err := filepath.Abs(srcPath)
 	if err != nil {
-		return false, ""
+		return moveEvent{}, false
 	}
-	dstCandidate := filepath.Join(dstDir, filepath.Base(srcAbs))
+	dstCandidate := filepath.Join(dstDir, rule.TargetName(srcAbs))
 	dstAbs, err := filepath.Abs(dstCandidate)
 	if err != nil {
-		return false, ""
+		return moveEvent{}, false
 	}
 	if strings.EqualFold(srcAbs, dstAbs) {
-		return false, ""
+		return moveEvent{}, false
 	}
 
+	ev := moveEvent{At: time.Now(), Src: srcAbs, Size: info.Size(), Rule: rule.ID}
 	dstUnique, err := organize.EnsureUniquePath(dstAbs)
 	if err != nil {
-		return true, fmt.Sprintf("skip %s (%v)", srcAbs, err)
+		ev.Action, ev.Err = "skip", err
+		return ev, true
 	}
+	ev.Dst = dstUnique
 
 	if dryRun {
-		return true, fmt.Sprintf("[dry-run] %s -> %s", srcAbs, dstUnique)
+		ev.Action = "dry-run"
+		return ev, true
 	}
 
 	if err := moveFileWithRetry(srcAbs, dstUnique); err != nil {
-		return true, fmt.Sprintf("move failed %s (%v)", srcAbs, err)
+		ev.Action, ev.Err = "failed", err
+		return ev, true
 	}
-
-	if info.Size() > 4*1024*1024*1024 {
-		// Easter egg: exceptionally large drops get a special line.
-		return true, fmt.Sprintf("moved %s -> %s  [black-ice payload]", srcAbs, dstUnique)
+	ev.Action = "moved"
+	if journal != nil {
+		entry, err := journal.Record(organize.SourceWatch, srcAbs, dstUnique)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "journal error: %v\n", err)
+		}
+		ev.ID = entry.ID
 	}
-	return true, fmt.Sprintf("moved %s -> %s", srcAbs, dstUnique)
+	return ev, true
 }
 
 func moveFileWithRetry(src, dst string) error {
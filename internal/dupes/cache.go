@@ -0,0 +1,165 @@
+package dupes
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"icicle/internal/appdir"
+)
+
+const cacheVersion = 1
+
+// cacheTTL drops entries that no scan used for this long when the cache is saved.
+const cacheTTL = 90 * 24 * time.Hour
+
+type cacheEntry struct {
+	Size    int64
+	ModTime int64
+	// Partial is only valid for the PartialSize stored in the cache file.
+	Partial string
+	Full    string
+	Used    int64
+}
+
+type cacheFile struct {
+	Version     int
+	PartialSize int64
+	Entries     map[string]*cacheEntry
+}
+
+// Cache remembers file hashes between runs, keyed by path, size and modification time.
+// A file whose size or mtime changed is hashed again. It is safe for concurrent use.
+type Cache struct {
+	file string
+
+	mu          sync.Mutex
+	partialSize int64
+	entries     map[string]*cacheEntry
+	dirty       bool
+}
+
+// CachePath returns where the hash cache is stored under the icicle config dir.
+func CachePath() (string, error) {
+	dir, err := appdir.Dir("cache")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hashes.gob"), nil
+}
+
+// OpenCache loads the hash cache. A missing or unreadable cache file yields an empty cache.
+func OpenCache() (*Cache, error) {
+	file, err := CachePath()
+	if err != nil {
+		return nil, err
+	}
+	return openCacheFile(file)
+}
+
+func openCacheFile(file string) (*Cache, error) {
+	c := &Cache{file: file, entries: map[string]*cacheEntry{}}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var data cacheFile
+	if err := gob.NewDecoder(f).Decode(&data); err != nil || data.Version != cacheVersion {
+		return c, nil
+	}
+	if data.Entries != nil {
+		c.entries = data.Entries
+	}
+	c.partialSize = data.PartialSize
+	return c, nil
+}
+
+// Len returns the number of cached files.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Save writes the cache atomically if anything changed.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	cutoff := time.Now().Add(-cacheTTL).Unix()
+	for path, e := range c.entries {
+		if e.Used < cutoff {
+			delete(c.entries, path)
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.file), ".hashes-*.tmp")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(tmp).Encode(cacheFile{Version: cacheVersion, PartialSize: c.partialSize, Entries: c.entries})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// lookup returns the cached hashes of f if the file is unchanged; unknown hashes are empty.
+func (c *Cache) lookup(f File, partialSize int64) (partial, full string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[f.Path]
+	if e == nil || e.Size != f.Size || e.ModTime != f.ModTime.UnixNano() {
+		return "", ""
+	}
+	if now := time.Now().Unix(); e.Used < now-24*60*60 {
+		e.Used = now
+		c.dirty = true
+	}
+	if partialSize != c.partialSize {
+		return "", e.Full
+	}
+	return e.Partial, e.Full
+}
+
+// store records the hashes known for f; empty values keep what was cached before.
+func (c *Cache) store(f File, partialSize int64, partial, full string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if partialSize != c.partialSize {
+		// Partial hashes of another size are useless now.
+		for _, e := range c.entries {
+			e.Partial = ""
+		}
+		c.partialSize = partialSize
+	}
+	e := c.entries[f.Path]
+	if e == nil || e.Size != f.Size || e.ModTime != f.ModTime.UnixNano() {
+		e = &cacheEntry{Size: f.Size, ModTime: f.ModTime.UnixNano()}
+		c.entries[f.Path] = e
+	}
+	if partial != "" {
+		e.Partial = partial
+	}
+	if full != "" {
+		e.Full = full
+	}
+	e.Used = time.Now().Unix()
+	c.dirty = true
+}
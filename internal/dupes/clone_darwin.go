@@ -0,0 +1,18 @@
+package dupes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst as an APFS clone of src.
+func cloneFile(src, dst string, _ os.FileInfo) error {
+	err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV) {
+		return fmt.Errorf("%w: %v", ErrReflinkUnsupported, err)
+	}
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package dupes
+
+import "os"
+
+func cloneFile(string, string, os.FileInfo) error {
+	return ErrReflinkUnsupported
+}
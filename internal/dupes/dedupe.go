@@ -0,0 +1,278 @@
+package dupes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Method selects how a duplicate is replaced by a reference to the kept copy.
+type Method string
+
+const (
+	// MethodHardlink makes every copy a name of the same file. All names then share
+	// permissions, times and later edits.
+	MethodHardlink Method = "hardlink"
+	// MethodReflink makes each copy an independent file sharing data blocks with the kept
+	// one (FICLONE on Linux btrfs/xfs, clonefile on APFS). Edits stay copy-on-write.
+	MethodReflink Method = "reflink"
+	// MethodAuto tries a reflink and falls back to a hard link where cloning is unsupported.
+	MethodAuto Method = "auto"
+	// MethodDelete removes the other copies instead of linking them.
+	MethodDelete Method = "delete"
+)
+
+// ParseMethod accepts auto, hardlink, reflink or delete.
+func ParseMethod(s string) (Method, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return MethodAuto, nil
+	case "hardlink", "hard", "link":
+		return MethodHardlink, nil
+	case "reflink", "clone":
+		return MethodReflink, nil
+	case "delete", "remove":
+		return MethodDelete, nil
+	}
+	return MethodAuto, fmt.Errorf("unknown dedupe method %q (use auto, hardlink, reflink or delete)", s)
+}
+
+// ErrReflinkUnsupported is returned where the platform or filesystem cannot clone files.
+var ErrReflinkUnsupported = errors.New("reflinks are not supported here")
+
+// Replacement actions.
+const (
+	ActionReplaced = "replaced"
+	ActionDryRun   = "dry-run"
+	ActionSkip     = "skip"
+	ActionFailed   = "failed"
+)
+
+// DedupeOptions tunes Dedupe.
+type DedupeOptions struct {
+	Method Method
+	// DryRun checks every copy and reports what would be reclaimed without touching anything.
+	DryRun bool
+}
+
+// Replacement is the outcome for one duplicate copy.
+type Replacement struct {
+	Keep string
+	Path string
+	Size int64
+	// Method is what was requested; after a replacement with MethodAuto it tells which one applied.
+	Method Method
+	Action string
+	// Reclaimed is the space freed. A copy with other hard links outside the group frees nothing.
+	Reclaimed int64
+	Err       error
+}
+
+// DedupeResult sums up Dedupe.
+type DedupeResult struct {
+	Replacements []Replacement
+	Replaced     int
+	Skipped      int
+	Failed       int
+	Reclaimed    int64
+}
+
+// Dedupe keeps the first file of every group and replaces the others with hard links or
+// reflinks to it, or deletes them with MethodDelete. Reorder Group.Files, e.g. with
+// KeepPolicy.Apply, to choose which copy is kept.
+//
+// Before a copy is replaced its size and mtime must still match the scan and its content is
+// compared byte for byte with the kept file. The link is created under a temporary name in
+// the same folder and renamed over the copy, so a failure never leaves the path missing.
+func Dedupe(ctx context.Context, groups []Group, opts DedupeOptions) (*DedupeResult, error) {
+	if opts.Method == "" {
+		opts.Method = MethodAuto
+	}
+	res := &DedupeResult{}
+	for _, g := range groups {
+		if len(g.Files) < 2 {
+			continue
+		}
+		keep := g.Files[0]
+		for _, dup := range g.Files[1:] {
+			if err := ctx.Err(); err != nil {
+				return res, err
+			}
+			r := dedupeOne(keep, dup, opts)
+			switch r.Action {
+			case ActionReplaced, ActionDryRun:
+				res.Replaced++
+				res.Reclaimed += r.Reclaimed
+			case ActionSkip:
+				res.Skipped++
+			case ActionFailed:
+				res.Failed++
+			}
+			res.Replacements = append(res.Replacements, r)
+		}
+	}
+	return res, nil
+}
+
+// errAlreadyLinked marks copies that are already the same file as the kept one.
+var errAlreadyLinked = errors.New("already linked")
+
+func dedupeOne(keep, dup File, opts DedupeOptions) Replacement {
+	r := Replacement{Keep: keep.Path, Path: dup.Path, Size: dup.Size, Method: opts.Method}
+	dupInfo, err := checkPair(keep, dup)
+	if err != nil {
+		r.Action, r.Err = ActionSkip, err
+		return r
+	}
+	if links, err := linkCount(dup.Path); err == nil && links <= 1 {
+		r.Reclaimed = dup.Size
+	}
+	if opts.DryRun {
+		r.Action = ActionDryRun
+		return r
+	}
+	same, err := sameContent(keep.Path, dup.Path, dup.Size)
+	if err == nil && !same {
+		err = errors.New("content differs from the kept copy")
+	}
+	if err != nil {
+		r.Action, r.Err, r.Reclaimed = ActionSkip, err, 0
+		return r
+	}
+	r.Method, err = replace(keep.Path, dup.Path, opts.Method, dupInfo)
+	if err != nil {
+		r.Action, r.Err, r.Reclaimed = ActionFailed, err, 0
+		return r
+	}
+	r.Action = ActionReplaced
+	return r
+}
+
+// checkPair makes sure both files are still the regular files the scan saw and returns
+// the info of dup.
+func checkPair(keep, dup File) (os.FileInfo, error) {
+	var infos [2]os.FileInfo
+	for i, f := range []File{keep, dup} {
+		info, err := os.Lstat(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is no longer a regular file", f.Path)
+		}
+		if info.Size() != f.Size || !info.ModTime().Equal(f.ModTime) {
+			return nil, fmt.Errorf("%s changed since the scan", f.Path)
+		}
+		infos[i] = info
+	}
+	if os.SameFile(infos[0], infos[1]) {
+		return nil, errAlreadyLinked
+	}
+	return infos[1], nil
+}
+
+// SameContent reports whether two files have the same size and the same bytes.
+func SameContent(a, b string) (bool, error) {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	ib, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if ia.Size() != ib.Size() {
+		return false, nil
+	}
+	return sameContent(a, b, ia.Size())
+}
+
+func sameContent(a, b string, size int64) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	bufA := make([]byte, 256<<10)
+	bufB := make([]byte, 256<<10)
+	var off int64
+	for off < size {
+		n := int64(len(bufA))
+		if size-off < n {
+			n = size - off
+		}
+		if _, err := io.ReadFull(fa, bufA[:n]); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(fb, bufB[:n]); err != nil {
+			return false, err
+		}
+		if !bytes.Equal(bufA[:n], bufB[:n]) {
+			return false, nil
+		}
+		off += n
+	}
+	return true, nil
+}
+
+// replace links keep to a temporary name next to dup and renames it over dup.
+func replace(keep, dup string, method Method, dupInfo os.FileInfo) (Method, error) {
+	if method == MethodDelete {
+		return method, os.Remove(dup)
+	}
+	tmp, err := tempName(dup)
+	if err != nil {
+		return method, err
+	}
+	used := method
+	switch method {
+	case MethodHardlink:
+		err = os.Link(keep, tmp)
+	case MethodReflink:
+		err = cloneFile(keep, tmp, dupInfo)
+	default:
+		used = MethodReflink
+		err = cloneFile(keep, tmp, dupInfo)
+		if errors.Is(err, ErrReflinkUnsupported) {
+			used = MethodHardlink
+			err = os.Link(keep, tmp)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return used, err
+	}
+	if used == MethodReflink {
+		// A clone is its own file; it keeps the replaced copy's mode and mtime.
+		_ = os.Chmod(tmp, dupInfo.Mode().Perm())
+		_ = os.Chtimes(tmp, dupInfo.ModTime(), dupInfo.ModTime())
+	}
+	if err := os.Rename(tmp, dup); err != nil {
+		os.Remove(tmp)
+		return used, err
+	}
+	return used, nil
+}
+
+// tempName picks an unused name in the folder of path.
+func tempName(path string) (string, error) {
+	dir, base := filepath.Split(path)
+	for i := 0; i < 16; i++ {
+		name := filepath.Join(dir, fmt.Sprintf(".%s.icicle-%08x.tmp", base, rand.Uint32()))
+		if _, err := os.Lstat(name); errors.Is(err, os.ErrNotExist) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no free temporary name next to %s", path)
+}
@@ -0,0 +1,347 @@
+package dupes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"icicle/internal/scan"
+	"icicle/internal/skip"
+)
+
+// DirOptions tunes FindDirs and DirHash.
+type DirOptions struct {
+	Scan scan.Options
+	// MinSize skips folders whose files add up to less.
+	MinSize  int64
+	Workers  int
+	Cache    *Cache
+	Progress func(Progress)
+}
+
+// DirGroup is a set of folders with identical trees: the same relative names and the same
+// file contents. Size and Files describe one copy.
+type DirGroup struct {
+	// Hash is the hex Merkle hash of the tree.
+	Hash  string
+	Size  int64
+	Files int
+	Dirs  []string
+}
+
+// Wasted is the space taken by all copies but one.
+func (g DirGroup) Wasted() int64 {
+	return g.Size * int64(len(g.Dirs)-1)
+}
+
+// DirResult is the outcome of FindDirs. Groups are sorted by wasted space, largest first.
+type DirResult struct {
+	Root       string
+	Groups     []DirGroup
+	Wasted     int64
+	HashedSize int64
+	HashErrors int
+	CacheHits  int
+	Walk       *scan.WalkStats
+}
+
+// ErrDirFilter is returned by FindDirs for options with a file filter: folders only match
+// when every file in them was compared.
+var ErrDirFilter = errors.New("duplicate folders cannot be searched with file filters")
+
+// FindDirs finds duplicate folder trees under root. Every folder gets a Merkle hash over the
+// names of its children, the content hashes of its files and the hashes of its subfolders,
+// empty ones included. Only files inside folders whose names and sizes already line up with
+// another folder are read. A group is left out when each of its folders sits inside a folder
+// that is itself reported, so a copied project shows up once instead of once per subfolder.
+//
+// Folders without files are not reported. Neither is a folder with a file or subfolder that
+// could not be read, whether during the walk or while hashing. Folders skipped by
+// opts.Scan.Skip are left out of the comparison; confirm with DirHash before removing a copy.
+func FindDirs(ctx context.Context, root string, opts DirOptions) (*DirResult, error) {
+	if opts.Scan.Filter != nil {
+		return nil, ErrDirFilter
+	}
+	root = filepath.Clean(root)
+	f, tree, walk, err := walkTree(ctx, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(walk.Errors.Items) < walk.Errors.Total() {
+		return nil, fmt.Errorf("too many unreadable paths to compare folders: %s", walk.Errors.Summary())
+	}
+	for _, pe := range walk.Errors.Items {
+		dir := pe.Path
+		if !pe.Dir {
+			dir = filepath.Dir(dir)
+		}
+		tree.node(dir).incomplete = true
+	}
+	nodes := tree.sorted()
+
+	// Folders can only match when their names and sizes match, so the shape pass narrows
+	// down which files need to be read.
+	byShape := map[string][]*dirNode{}
+	for _, n := range nodes {
+		if n.path != root && n.size >= opts.MinSize {
+			byShape[n.shape] = append(byShape[n.shape], n)
+		}
+	}
+	var toHash []File
+	for _, group := range byShape {
+		if len(group) < 2 {
+			continue
+		}
+		for _, n := range group {
+			if !n.queued {
+				toHash = n.queue(toHash)
+			}
+		}
+	}
+	sums, err := f.hashFiles(toHash)
+	if err != nil {
+		return nil, err
+	}
+	tree.merkle(nodes, sums)
+
+	byHash := map[string][]*dirNode{}
+	for _, n := range nodes {
+		if n.hash != "" && n.path != root && n.count > 0 && n.size >= opts.MinSize {
+			byHash[n.hash] = append(byHash[n.hash], n)
+		}
+	}
+	dup := map[string]bool{}
+	for _, group := range byHash {
+		if len(group) > 1 {
+			for _, n := range group {
+				dup[n.path] = true
+			}
+		}
+	}
+	res := &DirResult{Root: root, Walk: walk, HashedSize: f.progress.HashedSize, HashErrors: f.hashErrors, CacheHits: f.cacheHits}
+	for hash, group := range byHash {
+		if len(group) < 2 {
+			continue
+		}
+		covered := true
+		for _, n := range group {
+			if !dup[filepath.Dir(n.path)] {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+		g := DirGroup{Hash: hash, Size: group[0].size, Files: group[0].count}
+		for _, n := range group {
+			g.Dirs = append(g.Dirs, n.path)
+		}
+		sort.Strings(g.Dirs)
+		res.Groups = append(res.Groups, g)
+		res.Wasted += g.Wasted()
+	}
+	sort.Slice(res.Groups, func(i, j int) bool {
+		a, b := res.Groups[i], res.Groups[j]
+		if a.Wasted() != b.Wasted() {
+			return a.Wasted() > b.Wasted()
+		}
+		return a.Dirs[0] < b.Dirs[0]
+	})
+	return res, nil
+}
+
+// DirHash computes a Merkle hash of everything below dir, reading every file. Use it to
+// confirm that folders are still identical before acting on a DirGroup. Unlike FindDirs it
+// skips no folder, hashes every hard-link name and covers symlink targets, and it fails when
+// any path cannot be read. Only opts.Scan.Workers, opts.Workers and opts.Cache are used.
+// The hash matches DirGroup.Hash for trees without symlinks or skipped folders.
+func DirHash(ctx context.Context, dir string, opts DirOptions) (hash string, size int64, files int, err error) {
+	dir = filepath.Clean(dir)
+	opts.Scan = scan.Options{Workers: opts.Scan.Workers, Skip: &skip.Policy{NoDefaults: true}, AllLinks: true}
+	f, tree, walk, err := walkTree(ctx, dir, opts)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if walk.Errors.Total() > 0 {
+		return "", 0, 0, fmt.Errorf("could not read all of %s: %s", dir, walk.Errors.Summary())
+	}
+	if walk.Limited {
+		return "", 0, 0, fmt.Errorf("walk of %s stopped early", dir)
+	}
+	for _, l := range walk.Symlinks {
+		n := tree.node(filepath.Dir(l.Path))
+		n.links = append(n.links, "l\x00"+filepath.Base(l.Path)+"\x00"+l.Target)
+	}
+	top := tree.nodes[dir]
+	nodes := tree.sorted()
+	sums, err := f.hashFiles(top.queue(nil))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	tree.merkle(nodes, sums)
+	if top.hash == "" {
+		return "", 0, 0, errors.New("some files could not be read")
+	}
+	return top.hash, top.size, top.count, nil
+}
+
+func walkTree(ctx context.Context, root string, opts DirOptions) (*finder, *dirTree, *scan.WalkStats, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	opts.Scan.SizeMode = scan.SizeApparent
+	f := &finder{
+		opts:     Options{PartialSize: DefaultPartialSize, Workers: opts.Workers, Cache: opts.Cache, Progress: opts.Progress},
+		ctx:      ctx,
+		progress: Progress{Stage: StageScan},
+	}
+	tree := &dirTree{root: root, nodes: map[string]*dirNode{}}
+	walk, err := scan.WalkTreeContext(ctx, root, opts.Scan, func(fi scan.FileInfo) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.progress.Files++
+		if f.progress.Files%4096 == 0 {
+			f.report()
+		}
+		tree.add(File{Path: fi.Path, Size: fi.Size, ModTime: fi.ModTime})
+	}, func(dir string) {
+		f.mu.Lock()
+		tree.node(dir)
+		f.mu.Unlock()
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return f, tree, walk, nil
+}
+
+// hashFiles returns the content hash of every readable file.
+func (f *finder) hashFiles(files []File) (map[string]string, error) {
+	sums := make(map[string]string, len(files))
+	err := f.hashEach(StageFull, [][]File{files}, f.fullHash, func(file File, h string) {
+		sums[file.Path] = h
+	})
+	return sums, err
+}
+
+type dirTree struct {
+	root  string
+	nodes map[string]*dirNode
+}
+
+type dirNode struct {
+	path  string
+	depth int
+	files []File
+	dirs  []*dirNode
+	// size and count cover the whole subtree.
+	size   int64
+	count  int
+	shape  string
+	hash   string
+	queued bool
+	// links holds the symlink entries DirHash adds to the Merkle hash.
+	links []string
+	// incomplete marks folders where part of the walk failed; they never get a hash.
+	incomplete bool
+}
+
+// node returns the node for dir, creating it and its parents up to the root.
+func (t *dirTree) node(dir string) *dirNode {
+	if n := t.nodes[dir]; n != nil {
+		return n
+	}
+	n := &dirNode{path: dir}
+	t.nodes[dir] = n
+	if dir != t.root {
+		if parent := filepath.Dir(dir); parent != dir {
+			p := t.node(parent)
+			p.dirs = append(p.dirs, n)
+			n.depth = p.depth + 1
+		}
+	}
+	return n
+}
+
+func (t *dirTree) add(file File) {
+	n := t.node(filepath.Dir(file.Path))
+	n.files = append(n.files, file)
+}
+
+// sorted returns the nodes deepest first with their shapes and totals filled in.
+func (t *dirTree) sorted() []*dirNode {
+	nodes := make([]*dirNode, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].depth > nodes[j].depth })
+	for _, n := range nodes {
+		entries := make([]string, 0, len(n.files)+len(n.dirs))
+		n.size, n.count = 0, len(n.files)
+		for _, file := range n.files {
+			n.size += file.Size
+			entries = append(entries, fmt.Sprintf("f\x00%s\x00%d", filepath.Base(file.Path), file.Size))
+		}
+		for _, d := range n.dirs {
+			n.size += d.size
+			n.count += d.count
+			entries = append(entries, "d\x00"+filepath.Base(d.path)+"\x00"+d.shape)
+		}
+		n.shape = digest(entries)
+	}
+	return nodes
+}
+
+// queue appends every file of the subtree that was not queued yet.
+func (n *dirNode) queue(files []File) []File {
+	n.queued = true
+	files = append(files, n.files...)
+	for _, d := range n.dirs {
+		if !d.queued {
+			files = d.queue(files)
+		}
+	}
+	return files
+}
+
+// merkle sets the content hash of every node whose files were all hashed. nodes must be
+// deepest first.
+func (t *dirTree) merkle(nodes []*dirNode, sums map[string]string) {
+	for _, n := range nodes {
+		n.hash = ""
+		entries := make([]string, 0, len(n.files)+len(n.dirs)+len(n.links))
+		entries = append(entries, n.links...)
+		ok := !n.incomplete
+		for _, file := range n.files {
+			h, found := sums[file.Path]
+			if !found {
+				ok = false
+				break
+			}
+			entries = append(entries, "f\x00"+filepath.Base(file.Path)+"\x00"+h)
+		}
+		for _, d := range n.dirs {
+			if !ok || d.hash == "" {
+				ok = false
+				break
+			}
+			entries = append(entries, "d\x00"+filepath.Base(d.path)+"\x00"+d.hash)
+		}
+		if ok {
+			n.hash = digest(entries)
+		}
+	}
+}
+
+// digest hashes entries independent of their order.
+func digest(entries []string) string {
+	sort.Strings(entries)
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:])
+}
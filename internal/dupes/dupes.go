@@ -0,0 +1,398 @@
+// Package dupes finds files with identical content.
+//
+// Candidates are narrowed in three stages: equal size, then a hash of the head and tail of
+// each file, then a hash of the whole content. Files are only reported as duplicates after
+// the full-content stage, so files that share a prefix but differ later are never grouped.
+//
+// FindSimilar is the looser search for re-exported images and remuxed media.
+package dupes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"icicle/internal/scan"
+)
+
+// DefaultPartialSize is how much of the head and of the tail is read by the partial stage.
+const DefaultPartialSize = 64 << 10
+
+// DefaultWorkers bounds concurrent hashing. Hashing is mostly disk bound, so more workers
+// than this rarely help and hurt on spinning disks.
+const DefaultWorkers = 4
+
+// Stage names the step a Progress update belongs to.
+type Stage string
+
+const (
+	StageScan    Stage = "scan"
+	StagePartial Stage = "partial"
+	StageFull    Stage = "full"
+)
+
+// Options tunes Find. The zero value compares every non-empty file.
+type Options struct {
+	// Scan is passed to the walker; its Filter, Skip and MaxFiles limit the files compared.
+	Scan scan.Options
+	// MinSize skips smaller files; values below 1 still skip empty files.
+	MinSize int64
+	// PartialSize overrides DefaultPartialSize.
+	PartialSize int64
+	// Workers is how many files are hashed at once; 0 means DefaultWorkers.
+	Workers int
+	// Cache, when set, supplies hashes of unchanged files and records new ones.
+	// The caller saves it.
+	Cache *Cache
+	// Progress, when set, is called every few thousand walked files, at the start of each
+	// hashing stage and after every hashed file. Calls never overlap, but may come from
+	// different goroutines.
+	Progress func(Progress)
+}
+
+// Progress reports how far Find got.
+type Progress struct {
+	Stage Stage
+	// Files is the number of files walked so far (final once the scan stage is over).
+	Files int
+	// Candidates is the number of files the current stage has to hash.
+	Candidates int
+	Hashed     int
+	HashedSize int64
+}
+
+// File is one copy in a duplicate group.
+type File struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Group is a set of files with identical content, sorted by path.
+type Group struct {
+	Size int64
+	// Hash is the hex SHA-256 of the content.
+	Hash  string
+	Files []File
+}
+
+// Wasted is the space taken by all copies but one.
+func (g Group) Wasted() int64 {
+	return g.Size * int64(len(g.Files)-1)
+}
+
+// Result is the outcome of Find. Groups are sorted by wasted space, largest first.
+type Result struct {
+	Root   string
+	Groups []Group
+	// Wasted sums Group.Wasted over all groups.
+	Wasted int64
+	// HashedSize is the number of bytes read while hashing.
+	HashedSize int64
+	// HashErrors counts candidates that could not be read and were left out.
+	HashErrors int
+	// CacheHits counts hashes taken from Options.Cache instead of being read.
+	CacheHits int
+	Walk      *scan.WalkStats
+}
+
+// Find walks root and groups files with identical content.
+func Find(ctx context.Context, root string, opts Options) (*Result, error) {
+	if opts.MinSize < 1 {
+		opts.MinSize = 1
+	}
+	if opts.PartialSize <= 0 {
+		opts.PartialSize = DefaultPartialSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	// Content can only match between equal byte lengths.
+	opts.Scan.SizeMode = scan.SizeApparent
+	f := &finder{opts: opts, ctx: ctx, progress: Progress{Stage: StageScan}}
+
+	bySize := map[int64][]File{}
+	walk, err := scan.WalkInfoContext(ctx, root, opts.Scan, func(fi scan.FileInfo) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.progress.Files++
+		if f.progress.Files%4096 == 0 {
+			f.report()
+		}
+		if fi.Size >= opts.MinSize {
+			bySize[fi.Size] = append(bySize[fi.Size], File{Path: fi.Path, Size: fi.Size, ModTime: fi.ModTime})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var sizeGroups [][]File
+	for _, bucket := range bySize {
+		if len(bucket) > 1 {
+			sizeGroups = append(sizeGroups, bucket)
+		}
+	}
+	partial, err := f.regroup(StagePartial, sizeGroups, f.partialHash)
+	if err != nil {
+		return nil, err
+	}
+	full, err := f.regroup(StageFull, groupsOf(partial), f.fullHash)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{Root: root, Walk: walk, HashedSize: f.progress.HashedSize, HashErrors: f.hashErrors, CacheHits: f.cacheHits}
+	for key, files := range full {
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		g := Group{Size: files[0].Size, Hash: key.hash, Files: files}
+		res.Groups = append(res.Groups, g)
+		res.Wasted += g.Wasted()
+	}
+	sort.Slice(res.Groups, func(i, j int) bool {
+		a, b := res.Groups[i], res.Groups[j]
+		if a.Wasted() != b.Wasted() {
+			return a.Wasted() > b.Wasted()
+		}
+		return a.Files[0].Path < b.Files[0].Path
+	})
+	return res, nil
+}
+
+type finder struct {
+	opts Options
+	ctx  context.Context
+
+	// mu guards everything below; hashing itself runs outside it.
+	mu         sync.Mutex
+	progress   Progress
+	hashErrors int
+	cacheHits  int
+	// full remembers partial hashes that already covered the whole file.
+	full map[string]string
+}
+
+type groupKey struct {
+	size int64
+	hash string
+}
+
+// regroup hashes every file of the groups and splits them by hash; groups of one are dropped.
+func (f *finder) regroup(stage Stage, groups [][]File, hash func(File) (string, int64, error)) (map[groupKey][]File, error) {
+	out := map[groupKey][]File{}
+	err := f.hashEach(stage, groups, hash, func(file File, h string) {
+		key := groupKey{size: file.Size, hash: h}
+		out[key] = append(out[key], file)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key, g := range out {
+		if len(g) < 2 {
+			delete(out, key)
+		}
+	}
+	return out, nil
+}
+
+// hashEach hashes every file of the groups on a bounded pool of workers and hands each
+// hash to done, which runs with f.mu held. Files that cannot be read are counted and skipped.
+func (f *finder) hashEach(stage Stage, groups [][]File, hash func(File) (string, int64, error), done func(File, string)) error {
+	f.mu.Lock()
+	f.progress.Stage = stage
+	f.progress.Candidates = 0
+	f.progress.Hashed = 0
+	for _, g := range groups {
+		f.progress.Candidates += len(g)
+	}
+	f.report()
+	f.mu.Unlock()
+
+	jobs := make(chan File)
+	var wg sync.WaitGroup
+	for i := 0; i < f.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				h, read, err := hash(file)
+				f.mu.Lock()
+				f.progress.Hashed++
+				f.progress.HashedSize += read
+				if err != nil {
+					f.hashErrors++
+				} else {
+					done(file, h)
+				}
+				f.report()
+				f.mu.Unlock()
+			}
+		}()
+	}
+	err := f.feed(jobs, groups)
+	close(jobs)
+	wg.Wait()
+	return err
+}
+
+func (f *finder) feed(jobs chan<- File, groups [][]File) error {
+	for _, g := range groups {
+		for _, file := range g {
+			select {
+			case <-f.ctx.Done():
+				return f.ctx.Err()
+			case jobs <- file:
+			}
+		}
+	}
+	return f.ctx.Err()
+}
+
+func groupsOf(m map[groupKey][]File) [][]File {
+	out := make([][]File, 0, len(m))
+	for _, g := range m {
+		out = append(out, g)
+	}
+	return out
+}
+
+// report must be called with f.mu held.
+func (f *finder) report() {
+	if f.opts.Progress != nil {
+		f.opts.Progress(f.progress)
+	}
+}
+
+func (f *finder) cached(file File) (partial, full string) {
+	if f.opts.Cache == nil {
+		return "", ""
+	}
+	return f.opts.Cache.lookup(file, f.opts.PartialSize)
+}
+
+func (f *finder) hit() {
+	f.mu.Lock()
+	f.cacheHits++
+	f.mu.Unlock()
+}
+
+// partialHash hashes the head and tail of a file. For files no larger than both parts
+// together that is the whole content, which the full stage then reuses.
+func (f *finder) partialHash(file File) (string, int64, error) {
+	n := f.opts.PartialSize
+	small := file.Size <= 2*n
+	partial, full := f.cached(file)
+	if small && full != "" {
+		partial = full
+	}
+	if partial != "" {
+		if small {
+			f.remember(file, partial)
+		}
+		f.hit()
+		return partial, 0, nil
+	}
+	if small {
+		h, err := hashAll(f.ctx, file)
+		if err != nil {
+			return "", 0, err
+		}
+		f.remember(file, h)
+		f.store(file, "", h)
+		return h, file.Size, nil
+	}
+	h := sha256.New()
+	fh, err := os.Open(file.Path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer fh.Close()
+	if err := copyRange(f.ctx, h, fh, 0, n); err != nil {
+		return "", 0, err
+	}
+	if err := copyRange(f.ctx, h, fh, file.Size-n, n); err != nil {
+		return "", 0, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	f.store(file, sum, "")
+	return sum, 2 * n, nil
+}
+
+func (f *finder) fullHash(file File) (string, int64, error) {
+	f.mu.Lock()
+	h, ok := f.full[file.Path]
+	f.mu.Unlock()
+	if ok {
+		return h, 0, nil
+	}
+	if _, full := f.cached(file); full != "" {
+		f.hit()
+		return full, 0, nil
+	}
+	h, err := hashAll(f.ctx, file)
+	if err != nil {
+		return "", 0, err
+	}
+	f.store(file, "", h)
+	return h, file.Size, nil
+}
+
+func (f *finder) remember(file File, h string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.full == nil {
+		f.full = map[string]string{}
+	}
+	f.full[file.Path] = h
+}
+
+func (f *finder) store(file File, partial, full string) {
+	if f.opts.Cache != nil {
+		f.opts.Cache.store(file, f.opts.PartialSize, partial, full)
+	}
+}
+
+func hashAll(ctx context.Context, file File) (string, error) {
+	fh, err := os.Open(file.Path)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+	h := sha256.New()
+	if err := copyRange(ctx, h, fh, 0, file.Size); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// errChanged is returned when a file is shorter than its size from the walk.
+var errChanged = errors.New("file changed while hashing")
+
+// copyChunk is how much copyRange reads between checks for cancellation.
+const copyChunk = 1 << 20
+
+// copyRange copies n bytes at off and stops with ctx.Err() between chunks once ctx is cancelled.
+func copyRange(ctx context.Context, w io.Writer, r io.ReaderAt, off, n int64) error {
+	src := io.NewSectionReader(r, off, n)
+	var got int64
+	for got < n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c, err := io.CopyN(w, src, min(copyChunk, n-got))
+		got += c
+		if err == io.EOF {
+			return errChanged
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
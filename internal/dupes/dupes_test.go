@@ -0,0 +1,587 @@
+package dupes
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"icicle/internal/scan"
+	"icicle/internal/skip"
+)
+
+func write(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	big := bytes.Repeat([]byte("icicle"), 100)
+	// Same size, head and tail as big, but different in the middle.
+	mid := append([]byte(nil), big...)
+	mid[300] = 'X'
+
+	write(t, filepath.Join(root, "a", "big.bin"), big)
+	write(t, filepath.Join(root, "b", "big-copy.bin"), big)
+	write(t, filepath.Join(root, "c", "big-copy2.bin"), big)
+	write(t, filepath.Join(root, "a", "mid.bin"), mid)
+	write(t, filepath.Join(root, "a", "small.txt"), []byte("hello"))
+	write(t, filepath.Join(root, "b", "small.txt"), []byte("hello"))
+	write(t, filepath.Join(root, "b", "other.txt"), []byte("world"))
+	write(t, filepath.Join(root, "a", "empty1"), nil)
+	write(t, filepath.Join(root, "b", "empty2"), nil)
+
+	var stages []Stage
+	res, err := Find(context.Background(), root, Options{
+		PartialSize: 16,
+		Progress: func(p Progress) {
+			if len(stages) == 0 || stages[len(stages)-1] != p.Stage {
+				stages = append(stages, p.Stage)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Groups) != 2 {
+		t.Fatalf("want 2 groups, got %+v", res.Groups)
+	}
+	g := res.Groups[0]
+	if len(g.Files) != 3 || g.Size != int64(len(big)) || g.Wasted() != 2*int64(len(big)) {
+		t.Fatalf("unexpected first group: %+v", g)
+	}
+	if filepath.Base(g.Files[0].Path) != "big.bin" || len(g.Hash) != 64 {
+		t.Fatalf("files should be sorted by path and hashed: %+v", g)
+	}
+	if small := res.Groups[1]; len(small.Files) != 2 || small.Size != 5 {
+		t.Fatalf("unexpected second group: %+v", small)
+	}
+	if res.Wasted != 2*int64(len(big))+5 {
+		t.Fatalf("wasted = %d", res.Wasted)
+	}
+	if res.Walk.Seen != 9 {
+		t.Fatalf("seen = %d", res.Walk.Seen)
+	}
+	if len(stages) != 2 || stages[0] != StagePartial || stages[1] != StageFull {
+		t.Fatalf("stages = %v", stages)
+	}
+}
+
+func TestFindCancelled(t *testing.T) {
+	root := t.TempDir()
+	write(t, filepath.Join(root, "x"), []byte("same"))
+	write(t, filepath.Join(root, "y"), []byte("same"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Find(ctx, root, Options{}); err == nil {
+		t.Fatal("cancelled Find should fail")
+	}
+}
+
+// cancelWriter cancels its context after the first write.
+type cancelWriter struct {
+	cancel context.CancelFunc
+	n      int64
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	w.cancel()
+	return len(p), nil
+}
+
+func TestCopyRangeCancelled(t *testing.T) {
+	data := bytes.Repeat([]byte{7}, 4*copyChunk)
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &cancelWriter{cancel: cancel}
+	if err := copyRange(ctx, w, bytes.NewReader(data), 0, int64(len(data))); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if w.n >= int64(len(data)) {
+		t.Fatalf("copy did not stop early: %d bytes", w.n)
+	}
+	if err := copyRange(context.Background(), io.Discard, bytes.NewReader(data), 0, int64(len(data))+1); err != errChanged {
+		t.Fatalf("expected errChanged for a short file, got %v", err)
+	}
+}
+
+func TestFindCache(t *testing.T) {
+	root := t.TempDir()
+	big := bytes.Repeat([]byte("icicle"), 100)
+	write(t, filepath.Join(root, "a.bin"), big)
+	write(t, filepath.Join(root, "b.bin"), big)
+	write(t, filepath.Join(root, "c.txt"), []byte("hello"))
+	write(t, filepath.Join(root, "d.txt"), []byte("hello"))
+
+	file := filepath.Join(t.TempDir(), "hashes.gob")
+	cache, err := openCacheFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{PartialSize: 16, Workers: 3, Cache: cache}
+	first, err := Find(context.Background(), root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.CacheHits != 0 || first.HashedSize == 0 || len(first.Groups) != 2 {
+		t.Fatalf("first run: %+v", first)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = openCacheFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 4 {
+		t.Fatalf("cached %d files, want 4", cache.Len())
+	}
+	opts.Cache = cache
+	second, err := Find(context.Background(), root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.HashedSize != 0 || second.CacheHits == 0 || len(second.Groups) != 2 {
+		t.Fatalf("second run should only use the cache: %+v", second)
+	}
+	if second.Groups[0].Hash != first.Groups[0].Hash {
+		t.Fatalf("hash changed: %s vs %s", second.Groups[0].Hash, first.Groups[0].Hash)
+	}
+
+	// A rewritten file with a new size is hashed again and no longer matches.
+	write(t, filepath.Join(root, "b.bin"), append(big, '!'))
+	third, err := Find(context.Background(), root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(third.Groups) != 1 || third.Groups[0].Size != 5 {
+		t.Fatalf("changed file should drop out: %+v", third.Groups)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	root := t.TempDir()
+	data := bytes.Repeat([]byte("dedupe"), 1000)
+	for _, name := range []string{"a/keep.bin", "b/copy.bin", "c/copy.bin"} {
+		write(t, filepath.Join(root, name), data)
+	}
+	write(t, filepath.Join(root, "d/same-size.bin"), bytes.Repeat([]byte("x"), 5))
+	write(t, filepath.Join(root, "e/same-size.bin"), bytes.Repeat([]byte("x"), 5))
+	res, err := Find(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Groups) != 2 {
+		t.Fatalf("groups = %+v", res.Groups)
+	}
+
+	plan, err := Dedupe(context.Background(), res.Groups, DedupeOptions{Method: MethodHardlink, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Replaced != 3 || plan.Reclaimed != 2*int64(len(data))+5 {
+		t.Fatalf("dry run: %+v", plan)
+	}
+	keep, _ := os.Stat(filepath.Join(root, "a/keep.bin"))
+	copyInfo, _ := os.Stat(filepath.Join(root, "b/copy.bin"))
+	if os.SameFile(keep, copyInfo) {
+		t.Fatal("dry run must not link files")
+	}
+
+	// A copy edited in place after the scan is left alone.
+	edited := filepath.Join(root, "e/same-size.bin")
+	st, _ := os.Stat(edited)
+	write(t, edited, []byte("xxxxy"))
+	if err := os.Chtimes(edited, st.ModTime(), st.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := Dedupe(context.Background(), res.Groups, DedupeOptions{Method: MethodHardlink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Replaced != 2 || done.Skipped != 1 || done.Failed != 0 || done.Reclaimed != 2*int64(len(data)) {
+		t.Fatalf("dedupe: %+v", done)
+	}
+	for _, name := range []string{"b/copy.bin", "c/copy.bin"} {
+		info, err := os.Stat(filepath.Join(root, name))
+		if err != nil || !os.SameFile(keep, info) {
+			t.Fatalf("%s should be a hard link to the kept copy (%v)", name, err)
+		}
+	}
+	if got, _ := os.ReadFile(edited); string(got) != "xxxxy" {
+		t.Fatalf("edited copy was replaced: %q", got)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(root, "*", ".*.tmp"))
+	if len(leftovers) != 0 {
+		t.Fatalf("temporary files left: %v", leftovers)
+	}
+
+	again, err := Find(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Groups) != 0 {
+		t.Fatalf("linked copies should no longer be duplicates: %+v", again.Groups)
+	}
+}
+
+func TestDedupeAuto(t *testing.T) {
+	root := t.TempDir()
+	data := bytes.Repeat([]byte("clone"), 1000)
+	write(t, filepath.Join(root, "a"), data)
+	write(t, filepath.Join(root, "b"), data)
+	res, err := Find(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := Dedupe(context.Background(), res.Groups, DedupeOptions{Method: MethodAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Replaced != 1 {
+		t.Fatalf("auto: %+v", done.Replacements)
+	}
+	// Whichever method the filesystem allowed, the content must be intact.
+	if m := done.Replacements[0].Method; m != MethodReflink && m != MethodHardlink {
+		t.Fatalf("method = %q", m)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "b")); !bytes.Equal(got, data) {
+		t.Fatal("content changed")
+	}
+}
+
+func TestKeepPolicy(t *testing.T) {
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []File{
+		{Path: filepath.FromSlash("/home/u/Downloads/photo.jpg"), ModTime: old.Add(3 * time.Hour)},
+		{Path: filepath.FromSlash("/media/library/2020/photo.jpg"), ModTime: old},
+		{Path: filepath.FromSlash("/home/u/p.jpg"), ModTime: old.Add(time.Hour)},
+		{Path: filepath.FromSlash("/backup/photo.jpg"), ModTime: old.Add(2 * time.Hour)},
+	}
+	abs := func(p string) string { return filepath.FromSlash(p) }
+	for _, tc := range []struct {
+		spec string
+		want int
+	}{
+		{"", 0},
+		{"newest", 0},
+		{"oldest", 1},
+		{"shortest", 2},
+		{"longest", 1},
+		{"under:" + abs("/media/library"), 1},
+		{"not-under:Downloads; newest", 3},
+		{"not-under:Downloads\nshortest", 2},
+		{"under:/nowhere; oldest", 1},
+		{"roots:" + abs("/backup") + "|" + abs("/media/library"), 3},
+		{"roots:" + abs("/nowhere") + "|" + abs("/media"), 1},
+		{"under:library", 1},
+		{"under:ibrary; shortest", 2},
+	} {
+		p, err := ParseKeepRules(tc.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.spec, err)
+		}
+		if got := p.Choose(files); got != tc.want {
+			t.Errorf("%q keeps %s, want %s", tc.spec, files[got].Path, files[tc.want].Path)
+		}
+	}
+	for _, bad := range []string{"largest", "under:", "newest:x", "under:a|b"} {
+		if _, err := ParseKeepRules(bad); err == nil {
+			t.Errorf("%q should not parse", bad)
+		}
+	}
+
+	p, _ := ParseKeepRules("oldest")
+	groups := []Group{{Files: files}}
+	out := p.Apply(groups)
+	if out[0].Files[0].Path != files[1].Path || len(out[0].Files) != len(files) {
+		t.Fatalf("apply: %+v", out[0].Files)
+	}
+	if groups[0].Files[0].Path != files[0].Path {
+		t.Fatal("apply must not modify its input")
+	}
+}
+
+func TestSameContent(t *testing.T) {
+	root := t.TempDir()
+	write(t, filepath.Join(root, "a"), []byte("same bytes"))
+	write(t, filepath.Join(root, "b"), []byte("same bytes"))
+	write(t, filepath.Join(root, "c"), []byte("some bytes"))
+	write(t, filepath.Join(root, "d"), []byte("same"))
+	for _, tc := range []struct {
+		other string
+		want  bool
+	}{{"b", true}, {"c", false}, {"d", false}} {
+		got, err := SameContent(filepath.Join(root, "a"), filepath.Join(root, tc.other))
+		if err != nil || got != tc.want {
+			t.Fatalf("SameContent(a, %s) = %v, %v", tc.other, got, err)
+		}
+	}
+	if _, err := SameContent(filepath.Join(root, "a"), filepath.Join(root, "gone")); err == nil {
+		t.Fatal("missing file should fail")
+	}
+}
+
+func TestDedupeDelete(t *testing.T) {
+	root := t.TempDir()
+	write(t, filepath.Join(root, "Downloads", "a.txt"), []byte("same"))
+	write(t, filepath.Join(root, "keep", "a.txt"), []byte("same"))
+	res, err := Find(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep, _ := ParseKeepRules("not-under:Downloads")
+	done, err := Dedupe(context.Background(), keep.Apply(res.Groups), DedupeOptions{Method: MethodDelete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Replaced != 1 || done.Reclaimed != 4 {
+		t.Fatalf("delete: %+v", done.Replacements)
+	}
+	if _, err := os.Stat(filepath.Join(root, "Downloads", "a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("copy in Downloads should be gone: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "keep", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// picture draws a smooth test scene at any resolution.
+func picture(w, h int, invert bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := float64(x)/float64(w), float64(y)/float64(h)
+			v := uint8(255 * (0.5 + 0.5*math.Sin(6*fx)*math.Cos(4*fy)))
+			if invert {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{v, uint8(255 * fx), uint8(255 * fy), 255})
+		}
+	}
+	return img
+}
+
+func mp4Bytes(timescale, duration uint32, pad int) []byte {
+	box := func(typ string, payload []byte) []byte {
+		b := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+		return append(append(b, typ...), payload...)
+	}
+	mvhd := make([]byte, 20)
+	binary.BigEndian.PutUint32(mvhd[12:], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:], duration)
+	out := box("ftyp", []byte("isom\x00\x00\x02\x00"))
+	out = append(out, box("moov", box("mvhd", mvhd))...)
+	return append(out, box("mdat", make([]byte, pad))...)
+}
+
+func mkvBytes(ms float64, pad int) []byte {
+	out := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x80}
+	out = append(out, 0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	info := []byte{0x2A, 0xD7, 0xB1, 0x83, 0x0F, 0x42, 0x40, 0x44, 0x89, 0x88}
+	info = binary.BigEndian.AppendUint64(info, math.Float64bits(ms))
+	out = append(out, 0x15, 0x49, 0xA9, 0x66, 0x80|byte(len(info)))
+	out = append(out, info...)
+	// Void element with an 8-byte size carrying the padding.
+	out = append(out, 0xEC)
+	out = binary.BigEndian.AppendUint64(out, 1<<56|uint64(pad))
+	return append(out, make([]byte, pad)...)
+}
+
+func TestMediaDuration(t *testing.T) {
+	mp4 := mp4Bytes(600, 54000, 10)
+	if d, err := mp4Duration(bytes.NewReader(mp4), int64(len(mp4))); err != nil || d != 90*time.Second {
+		t.Fatalf("mp4: %v %v", d, err)
+	}
+	mkv := mkvBytes(90500, 10)
+	if d, err := mkvDuration(bytes.NewReader(mkv), int64(len(mkv))); err != nil || d != 90500*time.Millisecond {
+		t.Fatalf("mkv: %v %v", d, err)
+	}
+	if _, err := mp4Duration(bytes.NewReader([]byte("not a movie at all")), 18); err == nil {
+		t.Fatal("garbage should not parse")
+	}
+}
+
+func TestFindSimilar(t *testing.T) {
+	root := t.TempDir()
+	save := func(name string, img image.Image) {
+		t.Helper()
+		var buf bytes.Buffer
+		var err error
+		if filepath.Ext(name) == ".png" {
+			err = png.Encode(&buf, img)
+		} else {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 70})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(t, filepath.Join(root, name), buf.Bytes())
+	}
+	save("orig.png", picture(640, 480, false))
+	save("export/small.jpg", picture(200, 150, false))
+	save("other.png", picture(640, 480, true))
+	write(t, filepath.Join(root, "broken.jpg"), []byte("not an image"))
+	write(t, filepath.Join(root, "movie.mp4"), mp4Bytes(1000, 3600_000, 5000))
+	write(t, filepath.Join(root, "movie.mkv"), mkvBytes(3600_300, 4900))
+	write(t, filepath.Join(root, "episode.mp4"), mp4Bytes(1000, 3600_000, 1000))
+
+	res, err := FindSimilar(context.Background(), root, SimilarOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Compared != 6 || res.Unreadable != 1 || len(res.Groups) != 2 {
+		t.Fatalf("result: %+v", res)
+	}
+	var img, video *SimilarGroup
+	for i := range res.Groups {
+		switch res.Groups[i].Kind {
+		case KindImage:
+			img = &res.Groups[i]
+		case KindVideo:
+			video = &res.Groups[i]
+		}
+	}
+	if img == nil || len(img.Files) != 2 || filepath.Base(img.Files[0].Path) != "orig.png" || img.Files[0].Width != 640 {
+		t.Fatalf("image group: %+v", img)
+	}
+	if img.MaxDistance > DefaultMaxDistance {
+		t.Fatalf("distance %d", img.MaxDistance)
+	}
+	// The size check keeps the shorter-padded episode out of the movie group.
+	if video == nil || len(video.Files) != 2 || video.Files[1].Distance != 300 {
+		t.Fatalf("video group: %+v", video)
+	}
+}
+
+func TestFindDirs(t *testing.T) {
+	root := t.TempDir()
+	tree := map[string]string{
+		"src/main.go":        "package main",
+		"src/lib/util.go":    "package lib",
+		"src/lib/data.bin":   "0123456789",
+		"src/assets/a.txt":   "asset",
+		"docs/readme.md":     "hello",
+		"other/readme.md":    "hello",
+		"near/main.go":       "package main",
+		"near/lib/util.go":   "package lib",
+		"near/lib/data.bin":  "0123456789",
+		"near/assets/a.txt":  "ASSET",
+		"renamed/main.go":    "package main",
+		"renamed/lib/u.go":   "package lib",
+		"renamed/lib/data.b": "0123456789",
+	}
+	for name, data := range tree {
+		write(t, filepath.Join(root, filepath.FromSlash(name)), []byte(data))
+	}
+	for _, copyRoot := range []string{"backup/src", "old/src-copy"} {
+		for name, data := range tree {
+			if rel, ok := strings.CutPrefix(name, "src/"); ok {
+				write(t, filepath.Join(root, filepath.FromSlash(copyRoot), filepath.FromSlash(rel)), []byte(data))
+			}
+		}
+	}
+
+	res, err := FindDirs(context.Background(), root, DirOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	for _, g := range res.Groups {
+		var rels []string
+		for _, d := range g.Dirs {
+			rel, _ := filepath.Rel(root, d)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		got = append(got, rels)
+	}
+	// src and its copies are reported once; their identical lib folders are covered by
+	// them, while near/lib (next to a differing assets folder) is reported with them.
+	want := [][]string{
+		{"backup/src", "old/src-copy", "src"},
+		{"backup/src/lib", "near/lib", "old/src-copy/lib", "src/lib"},
+		{"docs", "other"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("groups = %v, want %v", got, want)
+	}
+	src := res.Groups[0]
+	if src.Files != 4 || src.Size != int64(len("package main")+len("package lib")+10+5) || src.Wasted() != 2*src.Size {
+		t.Fatalf("src group: %+v", src)
+	}
+
+	hash, size, files, err := DirHash(context.Background(), filepath.Join(root, "backup", "src"), DirOptions{})
+	if err != nil || hash != src.Hash || size != src.Size || files != src.Files {
+		t.Fatalf("DirHash = %s %d %d %v, want %s", hash, size, files, err, src.Hash)
+	}
+}
+
+func TestDirHashStrict(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"a", "b", "c", "d"} {
+		write(t, filepath.Join(root, d, "main.go"), []byte("package main"))
+	}
+	write(t, filepath.Join(root, "a", "cache", "x"), []byte("one"))
+	write(t, filepath.Join(root, "b", "cache", "x"), []byte("two"))
+	write(t, filepath.Join(root, "c", "cache", "x"), []byte("one"))
+	write(t, filepath.Join(root, "d", "cache", "x"), []byte("one"))
+	if err := os.MkdirAll(filepath.Join(root, "d", "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	opts := DirOptions{Scan: scan.Options{Skip: &skip.Policy{Patterns: []string{"cache"}}}}
+	hash := func(dir string) string {
+		t.Helper()
+		h, _, _, err := DirHash(context.Background(), filepath.Join(root, dir), opts)
+		if err != nil {
+			t.Fatalf("DirHash(%s): %v", dir, err)
+		}
+		return h
+	}
+	a, b, c, d := hash("a"), hash("b"), hash("c"), hash("d")
+	if a == b {
+		t.Fatal("content in a skipped folder must count")
+	}
+	if a != c {
+		t.Fatal("identical folders hash differently")
+	}
+	if a == d {
+		t.Fatal("an empty subfolder must count")
+	}
+	write(t, filepath.Join(root, "f", "x"), []byte("data"))
+	write(t, filepath.Join(root, "g", "x"), []byte("data"))
+	write(t, filepath.Join(root, "g", "y"), []byte("data"))
+	if err := os.Link(filepath.Join(root, "f", "x"), filepath.Join(root, "f", "y")); err == nil && hash("f") != hash("g") {
+		t.Fatal("every name of a hard-linked file must count")
+	}
+	if _, err := FindDirs(context.Background(), root, DirOptions{Scan: scan.Options{Filter: mustFilter(t, scan.FilterSpec{IncludeExt: []string{".go"}})}}); err != ErrDirFilter {
+		t.Fatalf("FindDirs with a filter = %v, want ErrDirFilter", err)
+	}
+}
+
+func mustFilter(t *testing.T, spec scan.FilterSpec) *scan.Filter {
+	t.Helper()
+	f, err := scan.NewFilter(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
@@ -0,0 +1,200 @@
+package dupes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// KeepPolicy decides which copy of a duplicate group stays. Rules are tried in order; each
+// one narrows the copies to those it ranks best, and a rule that cannot tell them apart
+// leaves them all to the next. Remaining ties go to the first copy by path.
+//
+// Rules:
+//
+//	newest, oldest          by modification time
+//	shortest, longest       by path length
+//	most-links              the copy with the most hard links
+//	under:DIR               copies inside DIR
+//	not-under:DIR           copies outside DIR
+//	roots:DIR1|DIR2|...     copies in the earliest listed root
+//
+// An absolute DIR (or one starting with ~) matches by prefix; a relative DIR such as
+// Downloads or media/library matches those path segments anywhere.
+type KeepPolicy []KeepRule
+
+// KeepRule is one parsed rule of a KeepPolicy.
+type KeepRule struct {
+	Kind string
+	Dirs []string
+}
+
+// ParseKeepRules parses rules separated by ";" or newlines, e.g. "not-under:Downloads; shortest".
+// An empty spec keeps the newest copy.
+func ParseKeepRules(spec string) (KeepPolicy, error) {
+	var p KeepPolicy
+	for _, part := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kind, arg, hasArg := strings.Cut(part, ":")
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		switch kind {
+		case "newest", "oldest", "shortest", "longest", "most-links":
+			if hasArg {
+				return nil, fmt.Errorf("keep rule %q takes no argument", kind)
+			}
+			p = append(p, KeepRule{Kind: kind})
+		case "under", "not-under", "roots":
+			var dirs []string
+			for _, d := range strings.Split(arg, "|") {
+				if d = strings.TrimSpace(d); d != "" {
+					dirs = append(dirs, keepDir(d))
+				}
+			}
+			if len(dirs) == 0 || (kind != "roots" && len(dirs) > 1) {
+				return nil, fmt.Errorf("keep rule %q needs a folder, e.g. %s:/media/library", kind, kind)
+			}
+			p = append(p, KeepRule{Kind: kind, Dirs: dirs})
+		default:
+			return nil, fmt.Errorf("unknown keep rule %q (use newest, oldest, shortest, longest, most-links, under:DIR, not-under:DIR or roots:A|B)", part)
+		}
+	}
+	if len(p) == 0 {
+		p = KeepPolicy{{Kind: "newest"}}
+	}
+	return p, nil
+}
+
+func (p KeepPolicy) String() string {
+	parts := make([]string, len(p))
+	for i, r := range p {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (r KeepRule) String() string {
+	if len(r.Dirs) == 0 {
+		return r.Kind
+	}
+	return r.Kind + ":" + strings.Join(r.Dirs, "|")
+}
+
+// Choose returns the index of the copy to keep.
+func (p KeepPolicy) Choose(files []File) int {
+	cands := make([]int, len(files))
+	for i := range cands {
+		cands[i] = i
+	}
+	for _, r := range p {
+		if len(cands) < 2 {
+			break
+		}
+		ranks := make([]int64, len(cands))
+		best := int64(0)
+		for i, c := range cands {
+			ranks[i] = r.rank(files[c])
+			if i == 0 || ranks[i] < best {
+				best = ranks[i]
+			}
+		}
+		next := cands[:0:0]
+		for i, c := range cands {
+			if ranks[i] == best {
+				next = append(next, c)
+			}
+		}
+		cands = next
+	}
+	keep := cands[0]
+	for _, c := range cands[1:] {
+		if files[c].Path < files[keep].Path {
+			keep = c
+		}
+	}
+	return keep
+}
+
+// Apply returns the groups with the kept copy moved first, ready for Dedupe.
+// The input groups are not modified.
+func (p KeepPolicy) Apply(groups []Group) []Group {
+	out := make([]Group, len(groups))
+	for i, g := range groups {
+		files := append([]File(nil), g.Files...)
+		if k := p.Choose(files); k > 0 {
+			keep := files[k]
+			copy(files[1:k+1], files[:k])
+			files[0] = keep
+		}
+		g.Files = files
+		out[i] = g
+	}
+	return out
+}
+
+// rank scores a copy for this rule; lower is better.
+func (r KeepRule) rank(f File) int64 {
+	switch r.Kind {
+	case "newest":
+		return -f.ModTime.UnixNano()
+	case "oldest":
+		return f.ModTime.UnixNano()
+	case "shortest":
+		return int64(utf8.RuneCountInString(f.Path))
+	case "longest":
+		return -int64(utf8.RuneCountInString(f.Path))
+	case "most-links":
+		n, err := linkCount(f.Path)
+		if err != nil {
+			n = 1
+		}
+		return -int64(n)
+	case "under":
+		if inDir(f.Path, r.Dirs[0]) {
+			return 0
+		}
+		return 1
+	case "not-under":
+		if inDir(f.Path, r.Dirs[0]) {
+			return 1
+		}
+		return 0
+	case "roots":
+		for i, d := range r.Dirs {
+			if inDir(f.Path, d) {
+				return int64(i)
+			}
+		}
+		return int64(len(r.Dirs))
+	}
+	return 0
+}
+
+// keepDir expands ~ and normalizes separators of a rule folder.
+func keepDir(d string) string {
+	if d == "~" || strings.HasPrefix(d, "~/") || strings.HasPrefix(d, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			d = filepath.Join(home, d[1:])
+		}
+	}
+	return filepath.Clean(d)
+}
+
+// inDir reports whether path lies inside dir. A relative dir matches its segments anywhere
+// in path.
+func inDir(path, dir string) bool {
+	p := filepath.ToSlash(filepath.Clean(path))
+	d := filepath.ToSlash(dir)
+	if runtime.GOOS == "windows" {
+		p, d = strings.ToLower(p), strings.ToLower(d)
+	}
+	if filepath.IsAbs(dir) {
+		return p == d || strings.HasPrefix(p, strings.TrimSuffix(d, "/")+"/")
+	}
+	return strings.Contains("/"+p+"/", "/"+d+"/")
+}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package dupes
+
+// linkCount cannot see hard links here and assumes one name per file.
+func linkCount(string) (uint64, error) {
+	return 1, nil
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package dupes
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount returns how many names the file at path has.
+func linkCount(path string) (uint64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink), nil
+	}
+	return 1, nil
+}
@@ -0,0 +1,21 @@
+package dupes
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// linkCount returns how many names the file at path has.
+func linkCount(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var d windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(windows.Handle(f.Fd()), &d); err != nil {
+		return 0, err
+	}
+	return uint64(d.NumberOfLinks), nil
+}
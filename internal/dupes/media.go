@@ -0,0 +1,217 @@
+package dupes
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errNoDuration is returned for containers whose header carries no duration.
+var errNoDuration = errors.New("no duration in media header")
+
+// mediaDuration reads the duration from an MP4/QuickTime or Matroska/WebM header.
+func mediaDuration(path string, size int64) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mkv", ".webm", ".mka":
+		return mkvDuration(f, size)
+	}
+	return mp4Duration(f, size)
+}
+
+// mp4Duration finds moov/mvhd and divides its duration by its timescale.
+func mp4Duration(r io.ReaderAt, size int64) (time.Duration, error) {
+	start, end, err := findBox(r, 0, size, "moov")
+	if err != nil {
+		return 0, err
+	}
+	start, end, err = findBox(r, start, end, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+	var hdr [32]byte
+	n, err := r.ReadAt(hdr[:min(int64(len(hdr)), end-start)], start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	var scale, dur uint64
+	switch {
+	case n >= 20 && hdr[0] == 0:
+		scale = uint64(binary.BigEndian.Uint32(hdr[12:]))
+		dur = uint64(binary.BigEndian.Uint32(hdr[16:]))
+	case n >= 32 && hdr[0] == 1:
+		scale = uint64(binary.BigEndian.Uint32(hdr[20:]))
+		dur = binary.BigEndian.Uint64(hdr[24:])
+	default:
+		return 0, fmt.Errorf("short mvhd box")
+	}
+	if scale == 0 || dur == 0 || dur == math.MaxUint32 || dur == math.MaxUint64 {
+		return 0, errNoDuration
+	}
+	return time.Duration(float64(dur) / float64(scale) * float64(time.Second)), nil
+}
+
+// findBox returns the payload range of the first box of type typ between start and end.
+func findBox(r io.ReaderAt, start, end int64, typ string) (int64, int64, error) {
+	var hdr [16]byte
+	for off := start; off+8 <= end; {
+		if _, err := r.ReadAt(hdr[:8], off); err != nil {
+			return 0, 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		head := int64(8)
+		switch size {
+		case 0:
+			size = end - off
+		case 1:
+			if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+				return 0, 0, err
+			}
+			size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			head = 16
+		}
+		if size < head || off+size > end {
+			return 0, 0, fmt.Errorf("bad %q box at %d", hdr[4:8], off)
+		}
+		if string(hdr[4:8]) == typ {
+			return off + head, off + size, nil
+		}
+		off += size
+	}
+	return 0, 0, fmt.Errorf("no %s box", typ)
+}
+
+// Matroska element ids.
+const (
+	ebmlHeader    = 0x1A45DFA3
+	mkvSegment    = 0x18538067
+	mkvInfo       = 0x1549A966
+	mkvCluster    = 0x1F43B675
+	mkvTimescale  = 0x2AD7B1
+	mkvDurationID = 0x4489
+)
+
+// mkvDuration reads Segment/Info/Duration scaled by TimecodeScale.
+func mkvDuration(r io.ReaderAt, size int64) (time.Duration, error) {
+	id, off, elSize, err := ebmlElement(r, 0)
+	if err != nil || id != ebmlHeader || elSize < 0 {
+		return 0, fmt.Errorf("not a matroska file")
+	}
+	off += elSize
+	id, off, segSize, err := ebmlElement(r, off)
+	if err != nil || id != mkvSegment {
+		return 0, fmt.Errorf("no matroska segment")
+	}
+	end := size
+	if segSize >= 0 && off+segSize < end {
+		end = off + segSize
+	}
+	for off < end {
+		id, data, elSize, err := ebmlElement(r, off)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case id == mkvCluster:
+			return 0, errNoDuration
+		case elSize < 0:
+			return 0, fmt.Errorf("unsized matroska element %x", id)
+		case id == mkvInfo:
+			return mkvInfoDuration(r, data, data+elSize)
+		}
+		off = data + elSize
+	}
+	return 0, errNoDuration
+}
+
+func mkvInfoDuration(r io.ReaderAt, off, end int64) (time.Duration, error) {
+	scale := uint64(1000000)
+	dur := -1.0
+	for off < end {
+		id, data, size, err := ebmlElement(r, off)
+		if err != nil {
+			return 0, err
+		}
+		if size < 0 || (size > 8 && (id == mkvTimescale || id == mkvDurationID)) {
+			return 0, fmt.Errorf("bad matroska info element %x", id)
+		}
+		if id == mkvTimescale || id == mkvDurationID {
+			buf := make([]byte, size)
+			if _, err := r.ReadAt(buf, data); err != nil {
+				return 0, err
+			}
+			switch {
+			case id == mkvTimescale:
+				scale = 0
+				for _, b := range buf {
+					scale = scale<<8 | uint64(b)
+				}
+			case size == 4:
+				dur = float64(math.Float32frombits(binary.BigEndian.Uint32(buf)))
+			case size == 8:
+				dur = math.Float64frombits(binary.BigEndian.Uint64(buf))
+			}
+		}
+		off = data + size
+	}
+	if dur <= 0 || scale == 0 {
+		return 0, errNoDuration
+	}
+	return time.Duration(dur * float64(scale)), nil
+}
+
+// ebmlElement reads the element header at off and returns its id, the offset of its data
+// and its size (-1 when unknown).
+func ebmlElement(r io.ReaderAt, off int64) (id uint64, data, size int64, err error) {
+	id, n, err := ebmlVint(r, off, true)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	sz, m, err := ebmlVint(r, off+int64(n), false)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	size = int64(sz)
+	if sz == 1<<(7*m)-1 {
+		size = -1
+	}
+	return id, off + int64(n+m), size, nil
+}
+
+// ebmlVint reads a variable-length integer; ids keep their length marker bit.
+func ebmlVint(r io.ReaderAt, off int64, keepMarker bool) (uint64, int, error) {
+	var buf [8]byte
+	if _, err := r.ReadAt(buf[:1], off); err != nil {
+		return 0, 0, err
+	}
+	n := 1
+	for mask := byte(0x80); n <= 8 && buf[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 {
+		return 0, 0, fmt.Errorf("bad matroska varint at %d", off)
+	}
+	if n > 1 {
+		if _, err := r.ReadAt(buf[1:n], off+1); err != nil {
+			return 0, 0, err
+		}
+	}
+	v := uint64(buf[0])
+	if !keepMarker {
+		v &= uint64(0xFF >> n)
+	}
+	for _, b := range buf[1:n] {
+		v = v<<8 | uint64(b)
+	}
+	return v, n, nil
+}
@@ -0,0 +1,148 @@
+package dupes
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// maxImagePixels keeps huge images from being decoded into memory.
+const maxImagePixels = 64 << 20
+
+// imageHash decodes a JPEG, PNG or GIF and returns its difference hash and dimensions.
+func imageHash(path string) (hash uint64, width, height int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return 0, 0, 0, fmt.Errorf("image too large to compare (%dx%d)", cfg.Width, cfg.Height)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, 0, err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return dhash(img), cfg.Width, cfg.Height, nil
+}
+
+// dhash shrinks the image to 9x8 gray cells and sets one bit per pair of horizontal
+// neighbours that gets darker to the right. Rescaled or recompressed copies of a picture
+// keep nearly the same bits.
+func dhash(img image.Image) uint64 {
+	b := img.Bounds()
+	var cells [8][9]float64
+	for y := 0; y < 8; y++ {
+		y0, y1 := span(b.Min.Y, b.Dy(), y, 8)
+		for x := 0; x < 9; x++ {
+			x0, x1 := span(b.Min.X, b.Dx(), x, 9)
+			cells[y][x] = meanGray(img, x0, y0, x1, y1)
+		}
+	}
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// span is the pixel range of cell i out of n along an axis of the given length.
+func span(min, length, i, n int) (int, int) {
+	lo := min + i*length/n
+	hi := min + (i+1)*length/n
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
+
+// meanGray averages the luminance of up to 16x16 samples of a cell.
+func meanGray(img image.Image, x0, y0, x1, y1 int) float64 {
+	stepX := max(1, (x1-x0)/16)
+	stepY := max(1, (y1-y0)/16)
+	var sum float64
+	n := 0
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+func hamming(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// bkTree indexes hashes by Hamming distance so near neighbours are found without comparing
+// every pair.
+type bkTree struct {
+	root *bkNode
+}
+
+type bkNode struct {
+	hash     uint64
+	id       int
+	children map[int]*bkNode
+}
+
+func (t *bkTree) add(hash uint64, id int) {
+	n := &bkNode{hash: hash, id: id}
+	if t.root == nil {
+		t.root = n
+		return
+	}
+	cur := t.root
+	for {
+		d := hamming(cur.hash, hash)
+		next := cur.children[d]
+		if next == nil {
+			if cur.children == nil {
+				cur.children = map[int]*bkNode{}
+			}
+			cur.children[d] = n
+			return
+		}
+		cur = next
+	}
+}
+
+// within calls fn for every indexed id whose hash is at most maxDist away.
+func (t *bkTree) within(hash uint64, maxDist int, fn func(id, dist int)) {
+	if t.root == nil {
+		return
+	}
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := hamming(n.hash, hash)
+		if d <= maxDist {
+			fn(n.id, d)
+		}
+		for cd, c := range n.children {
+			if cd >= d-maxDist && cd <= d+maxDist {
+				stack = append(stack, c)
+			}
+		}
+	}
+}
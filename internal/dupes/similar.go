@@ -0,0 +1,294 @@
+package dupes
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"icicle/internal/scan"
+)
+
+// Similarity kinds.
+const (
+	KindImage = "image"
+	KindVideo = "video"
+	KindAudio = "audio"
+)
+
+// StageSimilar is reported while images are hashed and media headers are read.
+const StageSimilar Stage = "similar"
+
+// Defaults for SimilarOptions.
+const (
+	DefaultMaxDistance       = 10
+	DefaultDurationTolerance = time.Second
+	DefaultSizeTolerance     = 0.1
+)
+
+var similarKinds = map[string]string{
+	".jpg": KindImage, ".jpeg": KindImage, ".png": KindImage, ".gif": KindImage,
+	".mp4": KindVideo, ".m4v": KindVideo, ".mov": KindVideo, ".mkv": KindVideo, ".webm": KindVideo,
+	".m4a": KindAudio, ".mka": KindAudio,
+}
+
+// SimilarOptions tunes FindSimilar.
+type SimilarOptions struct {
+	Scan    scan.Options
+	MinSize int64
+	// MaxDistance is the largest Hamming distance (of 64 bits) between the difference hashes
+	// of two images that still counts as the same picture.
+	MaxDistance int
+	// DurationTolerance and SizeTolerance bound how far apart two media files may be in
+	// duration and in size (as a fraction of the larger one).
+	DurationTolerance time.Duration
+	SizeTolerance     float64
+	Workers           int
+	Progress          func(Progress)
+}
+
+// SimilarFile is one member of a similarity group.
+type SimilarFile struct {
+	File
+	// Width and Height are set for images, Duration for media.
+	Width    int
+	Height   int
+	Duration time.Duration
+	// Distance to the group's first file: differing hash bits for images, milliseconds of
+	// duration for media.
+	Distance int
+}
+
+// SimilarGroup is a set of files that look like the same picture or recording. The first
+// file is the reference: the largest image by pixels, or the largest media file.
+type SimilarGroup struct {
+	Kind  string
+	Files []SimilarFile
+	// MaxDistance is the largest Distance in the group.
+	MaxDistance int
+}
+
+// Reclaimable is the size of every file but the reference.
+func (g SimilarGroup) Reclaimable() int64 {
+	var n int64
+	for _, f := range g.Files[1:] {
+		n += f.Size
+	}
+	return n
+}
+
+// SimilarResult is the outcome of FindSimilar. Groups are sorted by reclaimable size.
+type SimilarResult struct {
+	Root   string
+	Groups []SimilarGroup
+	// Compared counts images and media files that could be read; Unreadable the others.
+	Compared   int
+	Unreadable int
+	Walk       *scan.WalkStats
+}
+
+// FindSimilar groups near-duplicate JPEG, PNG and GIF images by perceptual hash, and
+// MP4/QuickTime/Matroska files by duration and size. Groups are transitive: two members
+// may be further apart than MaxDistance when a third file links them.
+func FindSimilar(ctx context.Context, root string, opts SimilarOptions) (*SimilarResult, error) {
+	if opts.MinSize < 1 {
+		opts.MinSize = 1
+	}
+	if opts.MaxDistance <= 0 {
+		opts.MaxDistance = DefaultMaxDistance
+	}
+	if opts.DurationTolerance <= 0 {
+		opts.DurationTolerance = DefaultDurationTolerance
+	}
+	if opts.SizeTolerance <= 0 {
+		opts.SizeTolerance = DefaultSizeTolerance
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	opts.Scan.SizeMode = scan.SizeApparent
+
+	var mu sync.Mutex
+	progress := Progress{Stage: StageScan}
+	report := func() {
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+	var files []SimilarFile
+	var kinds []string
+	walk, err := scan.WalkInfoContext(ctx, root, opts.Scan, func(fi scan.FileInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress.Files++
+		if progress.Files%4096 == 0 {
+			report()
+		}
+		kind, ok := similarKinds[strings.ToLower(filepath.Ext(fi.Path))]
+		if ok && fi.Size >= opts.MinSize {
+			files = append(files, SimilarFile{File: File{Path: fi.Path, Size: fi.Size, ModTime: fi.ModTime}})
+			kinds = append(kinds, kind)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	progress.Stage = StageSimilar
+	progress.Candidates = len(files)
+	report()
+	hashes := make([]uint64, len(files))
+	ok := make([]bool, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f := &files[i]
+				var err error
+				if kinds[i] == KindImage {
+					hashes[i], f.Width, f.Height, err = imageHash(f.Path)
+				} else {
+					f.Duration, err = mediaDuration(f.Path, f.Size)
+				}
+				ok[i] = err == nil
+				mu.Lock()
+				progress.Hashed++
+				progress.HashedSize += f.Size
+				report()
+				mu.Unlock()
+			}
+		}()
+	}
+	var cancelled error
+	for i := range files {
+		select {
+		case <-ctx.Done():
+			cancelled = ctx.Err()
+		case jobs <- i:
+			continue
+		}
+		break
+	}
+	close(jobs)
+	wg.Wait()
+	if cancelled != nil {
+		return nil, cancelled
+	}
+
+	res := &SimilarResult{Root: root, Walk: walk}
+	sets := newUnionFind(len(files))
+	var tree bkTree
+	var media []int
+	for i := range files {
+		if !ok[i] {
+			res.Unreadable++
+			continue
+		}
+		res.Compared++
+		if kinds[i] != KindImage {
+			media = append(media, i)
+			continue
+		}
+		tree.within(hashes[i], opts.MaxDistance, func(j, _ int) { sets.union(i, j) })
+		tree.add(hashes[i], i)
+	}
+	sort.Slice(media, func(a, b int) bool { return files[media[a]].Duration < files[media[b]].Duration })
+	for a, i := range media {
+		for _, j := range media[a+1:] {
+			if files[j].Duration-files[i].Duration > opts.DurationTolerance {
+				break
+			}
+			if kinds[i] == kinds[j] && sizeClose(files[i].Size, files[j].Size, opts.SizeTolerance) {
+				sets.union(i, j)
+			}
+		}
+	}
+
+	members := map[int][]int{}
+	for i := range files {
+		if ok[i] {
+			r := sets.find(i)
+			members[r] = append(members[r], i)
+		}
+	}
+	for _, ids := range members {
+		if len(ids) < 2 {
+			continue
+		}
+		res.Groups = append(res.Groups, similarGroup(kinds[ids[0]], ids, files, hashes))
+	}
+	sort.Slice(res.Groups, func(i, j int) bool {
+		a, b := res.Groups[i], res.Groups[j]
+		if a.Reclaimable() != b.Reclaimable() {
+			return a.Reclaimable() > b.Reclaimable()
+		}
+		return a.Files[0].Path < b.Files[0].Path
+	})
+	return res, nil
+}
+
+// similarGroup orders the members with the reference first and measures their distance to it.
+func similarGroup(kind string, ids []int, files []SimilarFile, hashes []uint64) SimilarGroup {
+	sort.Slice(ids, func(a, b int) bool {
+		fa, fb := files[ids[a]], files[ids[b]]
+		if pa, pb := fa.Width*fa.Height, fb.Width*fb.Height; pa != pb {
+			return pa > pb
+		}
+		if fa.Size != fb.Size {
+			return fa.Size > fb.Size
+		}
+		return fa.Path < fb.Path
+	})
+	g := SimilarGroup{Kind: kind}
+	ref := ids[0]
+	for _, i := range ids {
+		f := files[i]
+		if kind == KindImage {
+			f.Distance = hamming(hashes[ref], hashes[i])
+		} else {
+			d := f.Duration - files[ref].Duration
+			if d < 0 {
+				d = -d
+			}
+			f.Distance = int(d / time.Millisecond)
+		}
+		g.MaxDistance = max(g.MaxDistance, f.Distance)
+		g.Files = append(g.Files, f)
+	}
+	sort.SliceStable(g.Files[1:], func(a, b int) bool { return g.Files[1+a].Distance < g.Files[1+b].Distance })
+	return g
+}
+
+func sizeClose(a, b int64, tolerance float64) bool {
+	lo, hi := min(a, b), max(a, b)
+	return float64(hi-lo) <= tolerance*float64(hi)
+}
+
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	u := make(unionFind, n)
+	for i := range u {
+		u[i] = i
+	}
+	return u
+}
+
+func (u unionFind) find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+func (u unionFind) union(a, b int) {
+	if ra, rb := u.find(a), u.find(b); ra != rb {
+		u[rb] = ra
+	}
+}
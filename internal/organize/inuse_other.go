@@ -0,0 +1,8 @@
+//go:build !linux && !windows
+
+package organize
+
+// InUse cannot tell on this platform; the watcher relies on its quiet period alone.
+func InUse(path string) bool {
+	return false
+}
@@ -0,0 +1,22 @@
+package organize
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// InUse reports whether another process holds path open. It opens the file without
+// sharing, which Windows refuses while any other handle exists.
+func InUse(path string) bool {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return false
+	}
+	h, err := windows.CreateFile(p, windows.GENERIC_READ, 0, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+	}
+	windows.CloseHandle(h)
+	return false
+}
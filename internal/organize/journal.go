@@ -0,0 +1,236 @@
+package organize
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"icicle/internal/appdir"
+)
+
+// Journal entry operations.
+const (
+	OpMove = "move"
+	OpUndo = "undo"
+)
+
+// Journal sources.
+const (
+	SourceWatch = "watch"
+	SourceGUI   = "gui"
+	SourceCLI   = "cli"
+)
+
+// ErrNotAtDestination is returned by Undo when the moved file can no longer be found.
+var ErrNotAtDestination = errors.New("moved file is no longer at its destination")
+
+// JournalEntry is one line of the move journal.
+type JournalEntry struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Op     string    `json:"op"`
+	Source string    `json:"source"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	// Size and ModTime describe the file after the move; Undo uses them to recognize it.
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	// Undoes is the id of the move an undo entry reverses.
+	Undoes string `json:"undoes,omitempty"`
+}
+
+// Journal is the append-only record of file moves made by icicle watch and the desktop
+// app, one JSON object per line. Several processes may append to it at once.
+type Journal struct {
+	path string
+}
+
+// JournalPath is moves.jsonl next to the other icicle settings.
+func JournalPath() (string, error) {
+	dir, err := appdir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "moves.jsonl"), nil
+}
+
+// OpenJournal returns the shared journal. The file is created on the first move.
+func OpenJournal() (*Journal, error) {
+	path, err := JournalPath()
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{path: path}, nil
+}
+
+// Path is the journal file.
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Record appends a move of from to to. Call it after the move succeeded.
+func (j *Journal) Record(source, from, to string) (JournalEntry, error) {
+	e := JournalEntry{Op: OpMove, Source: source, From: from, To: to}
+	if info, err := os.Stat(to); err == nil {
+		e.Size, e.ModTime = info.Size(), info.ModTime()
+	}
+	return e, j.append(&e)
+}
+
+func (j *Journal) append(e *JournalEntry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.ID == "" {
+		e.ID = fmt.Sprintf("%s-%08x", e.Time.Format("20060102-150405"), rand.Uint32())
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	// One write per entry keeps lines from concurrent writers whole.
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns every entry, oldest first. Lines that do not parse are skipped.
+func (j *Journal) Entries() ([]JournalEntry, error) {
+	f, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []JournalEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var e JournalEntry
+		if json.Unmarshal(sc.Bytes(), &e) == nil && e.ID != "" {
+			out = append(out, e)
+		}
+	}
+	return out, sc.Err()
+}
+
+// Pending returns the moves that were not undone yet, oldest first.
+func (j *Journal) Pending() ([]JournalEntry, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return nil, err
+	}
+	undone := map[string]bool{}
+	for _, e := range entries {
+		if e.Op == OpUndo {
+			undone[e.Undoes] = true
+		}
+	}
+	var out []JournalEntry
+	for _, e := range entries {
+		if e.Op == OpMove && !undone[e.ID] {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// Undo moves the file of a recorded move back and records that. The file is looked for at
+// the move's destination, or, when that name is gone, as the only file in the destination
+// folder with the recorded size and modification time. When the original path is occupied
+// again the file is restored next to it under a free name. The returned entry tells where
+// the file was found (From) and where it went (To). With dryRun nothing is moved or recorded.
+func (j *Journal) Undo(move JournalEntry, source string, dryRun bool) (JournalEntry, error) {
+	if move.Op != OpMove {
+		return JournalEntry{}, fmt.Errorf("%s is not a move", move.ID)
+	}
+	// Files other pending moves left behind are not candidates for a renamed destination.
+	claimed := map[string]bool{}
+	if pending, err := j.Pending(); err == nil {
+		for _, e := range pending {
+			if e.ID != move.ID {
+				claimed[e.To] = true
+			}
+		}
+	}
+	cur, err := locateMoved(move, claimed)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	target, err := EnsureUniquePath(move.From)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	undo := JournalEntry{Op: OpUndo, Source: source, From: cur, To: target, Size: move.Size, ModTime: move.ModTime, Undoes: move.ID}
+	if dryRun {
+		return undo, nil
+	}
+	if err := MoveFile(cur, target); err != nil {
+		return JournalEntry{}, err
+	}
+	return undo, j.append(&undo)
+}
+
+// locateMoved finds the file a move left behind.
+func locateMoved(move JournalEntry, claimed map[string]bool) (string, error) {
+	info, err := os.Stat(move.To)
+	if err == nil {
+		if info.IsDir() || !sameFileState(move, info) {
+			return "", fmt.Errorf("%s was changed or replaced since the move", move.To)
+		}
+		return move.To, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if move.ModTime.IsZero() {
+		return "", ErrNotAtDestination
+	}
+	dir := filepath.Dir(move.To)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", ErrNotAtDestination
+	}
+	found := ""
+	for _, d := range entries {
+		path := filepath.Join(dir, d.Name())
+		if !d.Type().IsRegular() || claimed[path] {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil || !sameFileState(move, info) {
+			continue
+		}
+		if found != "" {
+			return "", fmt.Errorf("%w: several renamed candidates in %s", ErrNotAtDestination, dir)
+		}
+		found = path
+	}
+	if found == "" {
+		return "", ErrNotAtDestination
+	}
+	return found, nil
+}
+
+func sameFileState(move JournalEntry, info os.FileInfo) bool {
+	if move.ModTime.IsZero() {
+		return true
+	}
+	return info.Size() == move.Size && info.ModTime().Equal(move.ModTime)
+}
@@ -0,0 +1,207 @@
+package organize
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"icicle/internal/appdir"
+)
+
+// BuiltinRuleID names the built-in extension map when Route falls back to it.
+const BuiltinRuleID = "builtin"
+
+// RouteRule sends matching files to Target. Rules are shared by the desktop app and
+// icicle watch through routing_rules.json.
+type RouteRule struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Enabled  bool   `json:"enabled"`
+	Kind     string `json:"kind"` // ext|contains|prefix|regex|mime
+	Pattern  string `json:"pattern"`
+	Target   string `json:"target"`
+	Priority int    `json:"priority"`
+	// AddExt gives a moved file without an extension the one of its detected type.
+	AddExt bool `json:"addExt"`
+}
+
+// RouteRulesPath is routing_rules.json next to the other icicle settings.
+func RouteRulesPath() (string, error) {
+	dir, err := appdir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "routing_rules.json"), nil
+}
+
+// LoadRouteRules reads and normalizes a rules file; a missing file yields no rules.
+func LoadRouteRules(path string) ([]RouteRule, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []RouteRule{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []RouteRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return NormalizeRouteRules(rules), nil
+}
+
+// SaveRouteRules normalizes rules and writes them to path.
+func SaveRouteRules(path string, rules []RouteRule) ([]RouteRule, error) {
+	rules = NormalizeRouteRules(rules)
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// NormalizeRouteRules trims the fields, fills in missing names, kinds and ids, and drops
+// rules without a pattern or target and rules whose id was already seen.
+func NormalizeRouteRules(in []RouteRule) []RouteRule {
+	out := make([]RouteRule, 0, len(in))
+	seen := map[string]bool{}
+	for i, r := range in {
+		r.Name = strings.TrimSpace(r.Name)
+		r.Kind = strings.ToLower(strings.TrimSpace(r.Kind))
+		r.Pattern = strings.TrimSpace(r.Pattern)
+		r.Target = strings.TrimSpace(r.Target)
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("Rule %d", i+1)
+		}
+		if r.Kind == "" {
+			r.Kind = "ext"
+		}
+		if r.ID == "" {
+			r.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		if seen[strings.ToLower(r.ID)] {
+			continue
+		}
+		if r.Pattern == "" || r.Target == "" {
+			continue
+		}
+		seen[strings.ToLower(r.ID)] = true
+		out = append(out, r)
+	}
+	return out
+}
+
+// SortRouteRules orders rules by priority, lowest first, keeping the file order for ties.
+func SortRouteRules(rules []RouteRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+}
+
+// Matches reports whether path matches the rule. Matching is case-insensitive except for
+// regex rules. Mime rules read the start of the file and match its detected type against
+// a comma-separated list such as "application/zip, application/x-7z-compressed" or "video/*".
+func (r RouteRule) Matches(path string) bool {
+	_, ok := r.match(path)
+	return ok
+}
+
+// match is Matches that also returns the capture groups of a regex rule.
+func (r RouteRule) match(path string) ([]string, bool) {
+	raw := strings.TrimSpace(path)
+	if raw == "" {
+		return nil, false
+	}
+	p := strings.ToLower(raw)
+	pat := strings.ToLower(strings.TrimSpace(r.Pattern))
+	if pat == "" {
+		return nil, false
+	}
+	switch r.Kind {
+	case "contains":
+		return nil, strings.Contains(p, pat)
+	case "prefix":
+		return nil, strings.HasPrefix(p, pat)
+	case "regex":
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, false
+		}
+		groups := re.FindStringSubmatch(raw)
+		return groups, groups != nil
+	case "mime":
+		t, err := DetectType(raw)
+		if err != nil {
+			return nil, false
+		}
+		return nil, mimeMatches(pat, t)
+	default:
+		ext := strings.ToLower(filepath.Ext(raw))
+		if !strings.HasPrefix(pat, ".") {
+			pat = "." + pat
+		}
+		return nil, ext == pat
+	}
+}
+
+// ExpandRouteTarget expands a leading ~, environment variables and {home} in a rule target.
+// The file placeholders are left for Route.
+func ExpandRouteTarget(target string, home string) string {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return ""
+	}
+	if target == "~" || strings.HasPrefix(target, "~/") || strings.HasPrefix(target, `~\`) {
+		target = "{home}" + target[1:]
+	}
+	target = os.ExpandEnv(target)
+	target = strings.ReplaceAll(target, "{home}", home)
+	target = filepath.Clean(target)
+	return target
+}
+
+// Route picks the destination folder for src: the first enabled rule that matches and
+// whose target expands, else the built-in extension map (reported with BuiltinRuleID).
+// A file without an extension is looked up by its detected type. rules must already be
+// sorted with SortRouteRules; the file name to use comes from the returned rule's TargetName.
+func Route(rules []RouteRule, home, src string) (string, RouteRule, bool) {
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		groups, ok := r.match(src)
+		if !ok {
+			continue
+		}
+		target := ExpandRouteTarget(r.Target, home)
+		if target == "" {
+			continue
+		}
+		target, err := expandTemplate(target, src, groups)
+		if err != nil {
+			continue
+		}
+		return target, r, true
+	}
+	builtin := RouteRule{ID: BuiltinRuleID, Name: "builtin-extension", Enabled: true, Kind: "ext"}
+	if dst, ok := DestinationDir(home, src); ok {
+		return dst, builtin, true
+	}
+	if filepath.Ext(src) == "" {
+		if category, ok := sniffCategory(src); ok {
+			return filepath.Join(home, category), builtin, true
+		}
+	}
+	return "", RouteRule{}, false
+}
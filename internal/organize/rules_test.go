@@ -1,9 +1,12 @@
 package organize
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestDestinationDir(t *testing.T) {
@@ -43,3 +46,315 @@ func TestEnsureUniquePath(t *testing.T) {
 func osWrite(path string) error {
 	return os.WriteFile(path, []byte("x"), 0o644)
 }
+
+func TestRouteRuleMatches(t *testing.T) {
+	cases := []struct {
+		rule RouteRule
+		path string
+		want bool
+	}{
+		{RouteRule{Kind: "ext", Pattern: "PDF"}, "/tmp/Invoice.pdf", true},
+		{RouteRule{Kind: "ext", Pattern: ".pdf"}, "/tmp/invoice.pdf.part", false},
+		{RouteRule{Kind: "contains", Pattern: "Invoice"}, "/tmp/my-invoice-03.pdf", true},
+		{RouteRule{Kind: "prefix", Pattern: "/tmp/scans"}, "/tmp/Scans/a.png", true},
+		{RouteRule{Kind: "regex", Pattern: `(?i)^.*/IMG_\d+\.jpg$`}, "/tmp/img_0042.JPG", true},
+		{RouteRule{Kind: "regex", Pattern: `[`}, "/tmp/a", false},
+	}
+	for _, c := range cases {
+		if got := c.rule.Matches(c.path); got != c.want {
+			t.Errorf("%s %q on %q: got %v want %v", c.rule.Kind, c.rule.Pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeRouteRules(t *testing.T) {
+	got := NormalizeRouteRules([]RouteRule{
+		{Kind: " EXT ", Pattern: " pdf ", Target: " {home}/Docs "},
+		{ID: "rule-1", Pattern: "zip", Target: "/a"},
+		{ID: "empty", Target: "/b"},
+	})
+	if len(got) != 1 {
+		t.Fatalf("got %d rules, want 1: %+v", len(got), got)
+	}
+	r := got[0]
+	if r.ID != "rule-1" || r.Name != "Rule 1" || r.Kind != "ext" || r.Pattern != "pdf" || r.Target != "{home}/Docs" {
+		t.Fatalf("unexpected rule %+v", r)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	home := filepath.Clean("/home/demo")
+	rules := []RouteRule{
+		{ID: "late", Enabled: true, Kind: "ext", Pattern: "pdf", Target: "{home}/Late", Priority: 5},
+		{ID: "off", Enabled: false, Kind: "contains", Pattern: "invoice", Target: "{home}/Off", Priority: 0},
+		{ID: "invoices", Enabled: true, Kind: "contains", Pattern: "invoice", Target: "{home}/Invoices", Priority: 1},
+	}
+	SortRouteRules(rules)
+
+	dst, rule, ok := Route(rules, home, "/tmp/invoice-7.pdf")
+	if !ok || rule.ID != "invoices" || dst != filepath.Join(home, "Invoices") {
+		t.Fatalf("got %q %q %v", dst, rule.ID, ok)
+	}
+	dst, rule, ok = Route(rules, home, "/tmp/manual.pdf")
+	if !ok || rule.ID != "late" || dst != filepath.Join(home, "Late") {
+		t.Fatalf("got %q %q %v", dst, rule.ID, ok)
+	}
+	dst, rule, ok = Route(rules, home, "/tmp/clip.mp4")
+	if !ok || rule.ID != BuiltinRuleID || dst != filepath.Join(home, "Videos") {
+		t.Fatalf("got %q %q %v", dst, rule.ID, ok)
+	}
+	if _, _, ok := Route(rules, home, "/tmp/file.unknown"); ok {
+		t.Fatalf("expected no route for unknown extension")
+	}
+}
+
+func TestLoadRouteRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routing_rules.json")
+	rules, err := LoadRouteRules(path)
+	if err != nil || len(rules) != 0 {
+		t.Fatalf("missing file: %v %v", rules, err)
+	}
+	if _, err := SaveRouteRules(path, []RouteRule{{Enabled: true, Pattern: "iso", Target: "/isos"}, {Pattern: "x"}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	rules, err = LoadRouteRules(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(rules) != 1 || rules[0].ID != "rule-1" || !rules[0].Enabled {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+}
+
+func TestRouteTemplates(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "Inbox", "ACME-2024-03.PDF")
+	if err := os.MkdirAll(filepath.Dir(src), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := osWrite(src); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2023, time.July, 4, 12, 0, 0, 0, time.Local)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	home := filepath.Join(dir, "home")
+	rules := []RouteRule{{
+		ID: "acme", Enabled: true, Kind: "regex",
+		Pattern: `([A-Z]+)-(\d{4})-\d+\.PDF$`,
+		Target:  "~/Invoices/{1}/{2}/{year}-{month}-{day}/{parent}/{name}.{ext}/{category}/{size_bucket}/{unknown}",
+	}}
+	got, _, ok := Route(rules, home, src)
+	if !ok {
+		t.Fatalf("expected a route")
+	}
+	want := filepath.Join(home, "Invoices", "ACME", "2024", "2023-07-04", "Inbox", "ACME-2024-03.pdf", "Documents", "tiny", "{unknown}")
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+
+	got, err := ExpandTarget("{home}/{3}/{ext}", home, filepath.Join(dir, "README"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "noext"); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if _, err := ExpandTarget("{home}/{year}", home, filepath.Join(dir, "missing.txt")); err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
+
+func TestDetectType(t *testing.T) {
+	iso := make([]byte, 0x8006)
+	copy(iso[0x8001:], "CD001")
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+	cases := []struct {
+		data []byte
+		want string
+	}{
+		{[]byte("PK\x03\x04\x14\x00\x00\x00"), "application/zip"},
+		{[]byte("7z\xBC\xAF\x27\x1C\x00\x04"), "application/x-7z-compressed"},
+		{[]byte("Rar!\x1A\x07\x01\x00"), "application/vnd.rar"},
+		{iso, "application/x-iso9660-image"},
+		{tar, "application/x-tar"},
+		{[]byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00"), "video/mp4"},
+		{[]byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00"), "video/quicktime"},
+		{[]byte("\x1A\x45\xDF\xA3\x9F\x42\x82\x84webm"), "video/webm"},
+		{[]byte("\x1A\x45\xDF\xA3\x9F\x42\x82\x88matroska"), "video/x-matroska"},
+		{[]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png"},
+		{[]byte("%PDF-1.7\n"), "application/pdf"},
+		{[]byte("just some notes\n"), "text/plain"},
+	}
+	dir := t.TempDir()
+	for i, c := range cases {
+		path := filepath.Join(dir, "f"+string(rune('a'+i)))
+		if err := os.WriteFile(path, c.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := DetectType(path)
+		if err != nil {
+			t.Fatalf("%s: %v", c.want, err)
+		}
+		if got != c.want {
+			t.Errorf("got %q want %q", got, c.want)
+		}
+	}
+}
+
+func TestRouteMime(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	bin := filepath.Join(dir, "download.bin")
+	noext := filepath.Join(dir, "download")
+	video := filepath.Join(dir, "clip")
+	for path, data := range map[string]string{
+		bin:   "PK\x03\x04\x14\x00\x00\x00",
+		noext: "PK\x03\x04\x14\x00\x00\x00",
+		video: "\x00\x00\x00\x18ftypisom\x00\x00\x00\x00",
+	} {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rules := []RouteRule{{ID: "zips", Enabled: true, Kind: "mime", Pattern: "application/x-7z-compressed, application/zip", Target: "{home}/Zips", AddExt: true}}
+
+	dst, rule, ok := Route(rules, home, bin)
+	if !ok || rule.ID != "zips" || dst != filepath.Join(home, "Zips") {
+		t.Fatalf("got %q %q %v", dst, rule.ID, ok)
+	}
+	if got := rule.TargetName(bin); got != "download.bin" {
+		t.Fatalf("existing extension changed: %q", got)
+	}
+	if got := rule.TargetName(noext); got != "download.zip" {
+		t.Fatalf("got name %q want download.zip", got)
+	}
+
+	dst, rule, ok = Route(nil, home, video)
+	if !ok || rule.ID != BuiltinRuleID || dst != filepath.Join(home, "Videos") {
+		t.Fatalf("extension-less video: got %q %q %v", dst, rule.ID, ok)
+	}
+	if got := rule.TargetName(video); got != "clip" {
+		t.Fatalf("built-in routing renamed the file: %q", got)
+	}
+	if !(RouteRule{Kind: "mime", Pattern: "video/*"}).Matches(video) {
+		t.Fatalf("expected video/* to match")
+	}
+}
+
+func TestIsPartialDownload(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"setup.exe.crdownload", "movie.mkv.PART", "~$report.docx", "Unconfirmed 1234.crdownload"} {
+		if !IsPartialDownload(filepath.Join(dir, name)) {
+			t.Errorf("%s should count as a partial download", name)
+		}
+	}
+	done := filepath.Join(dir, "archive.zip")
+	if err := osWrite(done); err != nil {
+		t.Fatal(err)
+	}
+	if IsPartialDownload(done) {
+		t.Fatalf("finished file reported as partial")
+	}
+	if err := osWrite(done + ".part"); err != nil {
+		t.Fatal(err)
+	}
+	if !IsPartialDownload(done) {
+		t.Fatalf("placeholder next to a .part file should count as partial")
+	}
+}
+
+func TestInUse(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		t.Skip("no in-use check on " + runtime.GOOS)
+	}
+	path := filepath.Join(t.TempDir(), "busy.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !InUse(path) {
+		f.Close()
+		t.Skip("filesystem does not report open writers")
+	}
+	f.Close()
+	if InUse(path) {
+		t.Fatalf("closed file still reported in use")
+	}
+}
+
+func TestJournalUndo(t *testing.T) {
+	dir := t.TempDir()
+	j := &Journal{path: filepath.Join(dir, "moves.jsonl")}
+	move := func(name string) JournalEntry {
+		t.Helper()
+		src := filepath.Join(dir, "in", name)
+		dst := filepath.Join(dir, "out", name)
+		if err := os.MkdirAll(filepath.Dir(src), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(src, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := MoveFile(src, dst); err != nil {
+			t.Fatal(err)
+		}
+		e, err := j.Record(SourceWatch, src, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	plain := move("plain.txt")
+	renamed := move("renamed.txt")
+	occupied := move("occupied.txt")
+	gone := move("gone.txt")
+
+	if err := os.Rename(renamed.To, filepath.Join(dir, "out", "renamed by user.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := osWrite(occupied.From); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(gone.To); err != nil {
+		t.Fatal(err)
+	}
+
+	if u, err := j.Undo(plain, SourceCLI, true); err != nil || u.To != plain.From {
+		t.Fatalf("dry run: %+v %v", u, err)
+	}
+	if _, err := os.Stat(plain.To); err != nil {
+		t.Fatalf("dry run moved the file: %v", err)
+	}
+	if u, err := j.Undo(plain, SourceCLI, false); err != nil || u.To != plain.From {
+		t.Fatalf("plain: %+v %v", u, err)
+	}
+	u, err := j.Undo(renamed, SourceCLI, false)
+	if err != nil || u.From != filepath.Join(dir, "out", "renamed by user.txt") || u.To != renamed.From {
+		t.Fatalf("renamed: %+v %v", u, err)
+	}
+	u, err = j.Undo(occupied, SourceCLI, false)
+	if err != nil || u.To == occupied.From {
+		t.Fatalf("occupied: %+v %v", u, err)
+	}
+	if data, err := os.ReadFile(u.To); err != nil || string(data) != "occupied.txt" {
+		t.Fatalf("restored copy: %q %v", data, err)
+	}
+	if _, err := j.Undo(gone, SourceCLI, false); !errors.Is(err, ErrNotAtDestination) {
+		t.Fatalf("gone: got %v", err)
+	}
+
+	pending, err := j.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != gone.ID {
+		t.Fatalf("pending: %+v", pending)
+	}
+	entries, err := j.Entries()
+	if err != nil || len(entries) != 7 {
+		t.Fatalf("got %d entries, want 7: %v", len(entries), err)
+	}
+}
@@ -0,0 +1,171 @@
+package organize
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen covers http.DetectContentType and the magic numbers below; ISO 9660 needs a
+// second read further in.
+const sniffLen = 512
+
+// isoMagicOffset is where the first ISO 9660 volume descriptor carries "CD001".
+const isoMagicOffset = 0x8001
+
+// magic lists signatures http.DetectContentType does not know or reports too vaguely.
+var magic = []struct {
+	offset int
+	sig    string
+	mime   string
+}{
+	{0, "7z\xBC\xAF\x27\x1C", "application/x-7z-compressed"},
+	{0, "Rar!\x1A\x07", "application/vnd.rar"},
+	{0, "\xFD7zXZ\x00", "application/x-xz"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\x28\xB5\x2F\xFD", "application/zstd"},
+	{0, "MSCF", "application/vnd.ms-cab-compressed"},
+	{0, "MZ", "application/vnd.microsoft.portable-executable"},
+	{0, "\x7FELF", "application/x-elf"},
+	{0, "fLaC", "audio/flac"},
+	{0, "OggS", "audio/ogg"},
+	{257, "ustar", "application/x-tar"},
+}
+
+// extByMime is the extension given to a detected type, without the dot.
+var extByMime = map[string]string{
+	"application/zip":                               "zip",
+	"application/x-7z-compressed":                   "7z",
+	"application/vnd.rar":                           "rar",
+	"application/x-gzip":                            "gz",
+	"application/x-xz":                              "xz",
+	"application/x-bzip2":                           "bz2",
+	"application/zstd":                              "zst",
+	"application/x-tar":                             "tar",
+	"application/x-iso9660-image":                   "iso",
+	"application/vnd.ms-cab-compressed":             "cab",
+	"application/vnd.microsoft.portable-executable": "exe",
+	"application/pdf":                               "pdf",
+	"image/jpeg":                                    "jpg",
+	"image/png":                                     "png",
+	"image/gif":                                     "gif",
+	"image/webp":                                    "webp",
+	"image/bmp":                                     "bmp",
+	"image/heic":                                    "heic",
+	"video/mp4":                                     "mp4",
+	"video/quicktime":                               "mov",
+	"video/x-matroska":                              "mkv",
+	"video/webm":                                    "webm",
+	"video/avi":                                     "avi",
+	"audio/mp4":                                     "m4a",
+	"audio/mpeg":                                    "mp3",
+	"audio/wave":                                    "wav",
+	"audio/flac":                                    "flac",
+	"audio/ogg":                                     "ogg",
+}
+
+// DetectType reads the first bytes of a file and returns its MIME type without
+// parameters, e.g. "application/zip". Unrecognized binary data is
+// "application/octet-stream".
+func DetectType(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	head = head[:n]
+	if t := sniffMagic(head); t != "" {
+		return t, nil
+	}
+	var cd [5]byte
+	if _, err := f.ReadAt(cd[:], isoMagicOffset); err == nil && string(cd[:]) == "CD001" {
+		return "application/x-iso9660-image", nil
+	}
+	t, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	return t, nil
+}
+
+// sniffMagic recognizes the signatures in magic plus the ISO base media (MP4, QuickTime,
+// HEIC) and Matroska containers.
+func sniffMagic(head []byte) string {
+	for _, m := range magic {
+		if len(head) >= m.offset+len(m.sig) && string(head[m.offset:m.offset+len(m.sig)]) == m.sig {
+			return m.mime
+		}
+	}
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "qt  ":
+			return "video/quicktime"
+		case "M4A ", "M4B ":
+			return "audio/mp4"
+		case "heic", "heix", "mif1", "msf1":
+			return "image/heic"
+		}
+		return "video/mp4"
+	}
+	if bytes.HasPrefix(head, []byte("\x1A\x45\xDF\xA3")) {
+		if bytes.Contains(head, []byte("webm")) {
+			return "video/webm"
+		}
+		return "video/x-matroska"
+	}
+	return ""
+}
+
+// MimeExt is the usual extension for a detected type, with the dot, or "" when there is none.
+func MimeExt(mime string) string {
+	if ext, ok := extByMime[mime]; ok {
+		return "." + ext
+	}
+	return ""
+}
+
+// mimeMatches reports whether a detected type matches a comma-separated list of types,
+// each either exact or a pattern such as image/* or application/x-*.
+func mimeMatches(patterns, mime string) bool {
+	for _, p := range strings.Split(patterns, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if ok, err := path.Match(p, mime); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffCategory routes a file without an extension by its content.
+func sniffCategory(src string) (string, bool) {
+	t, err := DetectType(src)
+	if err != nil {
+		return "", false
+	}
+	category, ok := byExtension[MimeExt(t)]
+	return category, ok
+}
+
+// TargetName is the file name src gets at its destination: its own name, with the
+// extension of its detected type appended when the rule asks for it and the name has none.
+func (r RouteRule) TargetName(src string) string {
+	name := filepath.Base(src)
+	if !r.AddExt || filepath.Ext(name) != "" {
+		return name
+	}
+	t, err := DetectType(src)
+	if err != nil {
+		return name
+	}
+	return name + MimeExt(t)
+}
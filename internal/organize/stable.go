@@ -0,0 +1,42 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// partialSuffixes mark files a browser or download tool is still writing.
+var partialSuffixes = []string{
+	".crdownload", // Chrome, Edge
+	".part",       // Firefox
+	".partial",    // older Edge
+	".download",   // Safari
+	".opdownload", // Opera
+	".!ut",        // uTorrent
+	".!qb",        // qBittorrent
+	".aria2",      // aria2 control file
+	".filepart",   // WinSCP
+	".tmp",
+}
+
+// IsPartialDownload reports whether path is an unfinished download: a temporary download
+// file, an Office lock file, or a placeholder next to a temporary file of the same name
+// (Firefox creates file.zip empty beside file.zip.part and replaces it when done).
+func IsPartialDownload(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	if strings.HasPrefix(name, "~$") || strings.HasPrefix(name, ".~lock.") {
+		return true
+	}
+	for _, suffix := range partialSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	for _, suffix := range []string{".part", ".crdownload", ".aria2"} {
+		if _, err := os.Lstat(path + suffix); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package organize
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Route targets are templates. Besides {home}, environment variables and a leading ~,
+// they may use placeholders filled in from the file being moved:
+//
+//	{ext}          extension without the dot, lower case ("noext" when missing)
+//	{name}         file name without its extension
+//	{parent}       name of the folder the file is in
+//	{year}         modification time: 2024
+//	{month}        modification time: 01..12
+//	{day}          modification time: 01..31
+//	{category}     built-in folder for the extension (Pictures, Archives, ...), else Other
+//	{size_bucket}  tiny (<1 MB), small (<100 MB), medium (<1 GB) or large
+//	{1}, {2}, ...  capture groups of a regex rule's pattern
+//
+// Unknown placeholders are left as they are.
+
+// ExpandTarget fills in the placeholders of a target template for src. Use it for
+// destinations that do not come from a rule; regex groups stay empty.
+func ExpandTarget(target, home, src string) (string, error) {
+	target = ExpandRouteTarget(target, home)
+	if target == "" {
+		return "", nil
+	}
+	return expandTemplate(target, src, nil)
+}
+
+// expandTemplate replaces the file placeholders of an already expanded target. The file is
+// only stat'ed when a placeholder needs its time or size.
+func expandTemplate(target, src string, groups []string) (string, error) {
+	if !strings.Contains(target, "{") {
+		return target, nil
+	}
+	var info os.FileInfo
+	stat := func() (os.FileInfo, error) {
+		if info != nil {
+			return info, nil
+		}
+		var err error
+		info, err = os.Stat(src)
+		return info, err
+	}
+	var b strings.Builder
+	rest := target
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			b.WriteString(rest)
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			b.WriteString(rest)
+			break
+		}
+		end += open
+		b.WriteString(rest[:open])
+		key := rest[open+1 : end]
+		value, ok, err := placeholder(key, src, groups, stat)
+		if err != nil {
+			return "", fmt.Errorf("target %s: {%s}: %w", target, key, err)
+		}
+		if ok {
+			b.WriteString(value)
+		} else {
+			b.WriteString(rest[open : end+1])
+		}
+		rest = rest[end+1:]
+	}
+	return filepath.Clean(b.String()), nil
+}
+
+func placeholder(key, src string, groups []string, stat func() (os.FileInfo, error)) (string, bool, error) {
+	ext := strings.ToLower(filepath.Ext(src))
+	switch key {
+	case "ext":
+		if ext == "" {
+			return "noext", true, nil
+		}
+		return safeSegment(ext[1:]), true, nil
+	case "name":
+		return safeSegment(strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))), true, nil
+	case "parent":
+		return safeSegment(filepath.Base(filepath.Dir(src))), true, nil
+	case "category":
+		if c, ok := byExtension[ext]; ok {
+			return c, true, nil
+		}
+		return "Other", true, nil
+	case "year", "month", "day":
+		info, err := stat()
+		if err != nil {
+			return "", false, err
+		}
+		t := info.ModTime()
+		switch key {
+		case "year":
+			return fmt.Sprintf("%04d", t.Year()), true, nil
+		case "month":
+			return fmt.Sprintf("%02d", int(t.Month())), true, nil
+		}
+		return fmt.Sprintf("%02d", t.Day()), true, nil
+	case "size_bucket":
+		info, err := stat()
+		if err != nil {
+			return "", false, err
+		}
+		return sizeBucket(info.Size()), true, nil
+	}
+	if n, err := strconv.Atoi(key); err == nil && n > 0 {
+		if n < len(groups) {
+			return safeSegment(groups[n]), true, nil
+		}
+		return "", true, nil
+	}
+	return "", false, nil
+}
+
+func sizeBucket(size int64) string {
+	switch {
+	case size < 1<<20:
+		return "tiny"
+	case size < 100<<20:
+		return "small"
+	case size < 1<<30:
+		return "medium"
+	}
+	return "large"
+}
+
+// safeSegment keeps a value taken from a file name from adding folders or climbing out of
+// the target.
+func safeSegment(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
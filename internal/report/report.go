@@ -0,0 +1,196 @@
+// Package report encodes command results as JSON, NDJSON, CSV or Markdown with stable column sets.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format selects the encoding. Text means the command's own human output.
+type Format string
+
+const (
+	Text     Format = "text"
+	JSON     Format = "json"
+	NDJSON   Format = "ndjson"
+	CSV      Format = "csv"
+	Markdown Format = "md"
+)
+
+// ParseFormat accepts text, json, ndjson, csv and md (or markdown).
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "text":
+		return Text, nil
+	case "json":
+		return JSON, nil
+	case "ndjson", "jsonl":
+		return NDJSON, nil
+	case "csv":
+		return CSV, nil
+	case "md", "markdown":
+		return Markdown, nil
+	}
+	return Text, fmt.Errorf("unknown format %q (use text, json, ndjson, csv or md)", s)
+}
+
+// Field is one ordered key/value of a report summary.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Encoder writes one report: a header, any number of rows and an optional summary.
+// Rows are flushed as they come, so long-running commands can stream them.
+//
+//	json:   {"kind":..., "root":..., "items":[{...}], "summary":{...}}
+//	ndjson: one object per row; the summary is not written
+//	csv:    header line with Columns, then rows; the summary is not written
+//	md:     heading, table, then the summary as a list
+type Encoder struct {
+	w       io.Writer
+	format  Format
+	kind    string
+	columns []string
+	rows    int
+	csv     *csv.Writer
+	err     error
+}
+
+// NewEncoder starts a report of the given kind (e.g. "heavy") for root.
+func NewEncoder(w io.Writer, format Format, kind string, root string, columns []string) *Encoder {
+	e := &Encoder{w: w, format: format, kind: kind, columns: columns}
+	switch format {
+	case JSON:
+		e.printf(`{"kind":%s,"root":%s,"items":[`, quote(kind), quote(root))
+	case CSV:
+		e.csv = csv.NewWriter(w)
+		e.err = e.csv.Write(columns)
+		e.csv.Flush()
+	case Markdown:
+		if root != "" {
+			e.printf("## %s: `%s`\n\n", kind, mdCode(root))
+		} else {
+			e.printf("## %s\n\n", kind)
+		}
+		e.printf("| %s |\n|", strings.Join(columns, " | "))
+		for range columns {
+			e.printf("---|")
+		}
+		e.printf("\n")
+	}
+	return e
+}
+
+// Row writes one item; values line up with the columns passed to NewEncoder.
+func (e *Encoder) Row(values ...any) error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(values) != len(e.columns) {
+		return fmt.Errorf("report: %d values for %d columns", len(values), len(e.columns))
+	}
+	switch e.format {
+	case JSON, NDJSON:
+		if e.format == JSON && e.rows > 0 {
+			e.printf(",")
+		}
+		e.printf("%s", e.object(values))
+		if e.format == NDJSON {
+			e.printf("\n")
+		}
+	case CSV:
+		rec := make([]string, len(values))
+		for i, v := range values {
+			rec[i] = fmt.Sprint(v)
+		}
+		if err := e.csv.Write(rec); err != nil {
+			e.err = err
+		}
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil && e.err == nil {
+			e.err = err
+		}
+	case Markdown:
+		cells := make([]string, len(values))
+		for i, v := range values {
+			cells[i] = mdCell(v)
+		}
+		e.printf("| %s |\n", strings.Join(cells, " | "))
+	}
+	e.rows++
+	return e.err
+}
+
+// Close finishes the report with an optional summary.
+func (e *Encoder) Close(summary ...Field) error {
+	switch e.format {
+	case JSON:
+		e.printf(`],"summary":{`)
+		for i, f := range summary {
+			if i > 0 {
+				e.printf(",")
+			}
+			e.printf("%s:%s", quote(f.Key), marshal(f.Value))
+		}
+		e.printf("}}\n")
+	case Markdown:
+		if len(summary) > 0 {
+			e.printf("\n")
+		}
+		for _, f := range summary {
+			e.printf("- %s: %s\n", f.Key, mdCell(f.Value))
+		}
+	}
+	return e.err
+}
+
+func (e *Encoder) object(values []any) string {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(quote(e.columns[i]))
+		b.WriteString(":")
+		b.WriteString(marshal(v))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func (e *Encoder) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = fmt.Fprintf(e.w, format, args...)
+}
+
+func quote(s string) string {
+	return marshal(s)
+}
+
+func marshal(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "null"
+	}
+	return string(b)
+}
+
+func mdCode(s string) string {
+	return strings.ReplaceAll(s, "`", "'")
+}
+
+func mdCell(v any) string {
+	s := strings.ReplaceAll(fmt.Sprint(v), "|", `\|`)
+	if _, ok := v.(string); ok && strings.ContainsAny(s, `/\`) {
+		// Paths go in code spans so underscores and asterisks stay literal.
+		return "`" + mdCode(s) + "`"
+	}
+	return s
+}
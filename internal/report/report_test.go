@@ -0,0 +1,81 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encode(t *testing.T, format Format) string {
+	t.Helper()
+	var b strings.Builder
+	enc := NewEncoder(&b, format, "heavy", "/data", HeavyColumns)
+	for _, r := range [][]any{HeavyRow("/data/a,b.bin", 2048, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), time.Time{}), HeavyRow("/data/c|d.iso", 10, time.Time{}, time.Time{})} {
+		if err := enc.Row(r...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(SizeFields("total", 2058)...); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestEncoderJSON(t *testing.T) {
+	var got struct {
+		Kind    string           `json:"kind"`
+		Root    string           `json:"root"`
+		Items   []map[string]any `json:"items"`
+		Summary map[string]any   `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(encode(t, JSON)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != "heavy" || got.Root != "/data" || len(got.Items) != 2 {
+		t.Fatalf("unexpected report: %+v", got)
+	}
+	if got.Items[0]["path"] != "/data/a,b.bin" || got.Items[0]["size_bytes"] != float64(2048) || got.Items[0]["size_human"] == "" ||
+		got.Items[0]["mtime"] != "2024-05-01T12:00:00Z" || got.Items[0]["atime"] != "" {
+		t.Fatalf("unexpected item: %v", got.Items[0])
+	}
+	if got.Summary["total_bytes"] != float64(2058) {
+		t.Fatalf("unexpected summary: %v", got.Summary)
+	}
+}
+
+func TestEncoderNDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(encode(t, NDJSON)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 lines, got %q", lines)
+	}
+	var row map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &row); err != nil || row["size_bytes"] != float64(10) {
+		t.Fatalf("bad row %q: %v", lines[1], err)
+	}
+}
+
+func TestEncoderCSV(t *testing.T) {
+	got := encode(t, CSV)
+	if !strings.HasPrefix(got, "path,size_bytes,size_human,mtime,atime\n") || !strings.Contains(got, `"/data/a,b.bin",2048,`) {
+		t.Fatalf("unexpected csv:\n%s", got)
+	}
+}
+
+func TestEncoderMarkdown(t *testing.T) {
+	got := encode(t, Markdown)
+	if !strings.Contains(got, "| path | size_bytes | size_human | mtime | atime |") || !strings.Contains(got, "`/data/c\\|d.iso`") || !strings.Contains(got, "- total_bytes: 2058") {
+		t.Fatalf("unexpected markdown:\n%s", got)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": Text, "JSON": JSON, "jsonl": NDJSON, "markdown": Markdown} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("xml should be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"math"
+	"time"
+
+	"icicle/internal/ui"
+)
+
+// Column sets shared by the CLI and the desktop exporter. Sizes are always raw bytes
+// with a human-readable twin; new columns are only ever appended.
+var (
+	HeavyColumns   = []string{"path", "size_bytes", "size_human", "mtime", "atime"}
+	TreeColumns    = []string{"path", "name", "depth", "size_bytes", "size_human", "files", "dirs"}
+	ExtColumns     = []string{"ext", "count", "size_bytes", "size_human", "share"}
+	MoveColumns    = []string{"time", "action", "src", "dst", "size_bytes", "error", "rule", "id"}
+	DupeColumns    = []string{"group", "hash", "path", "size_bytes", "size_human", "mtime"}
+	DedupeColumns  = []string{"action", "method", "path", "keep", "size_bytes", "size_human", "reclaimed_bytes", "error"}
+	SimilarColumns = []string{"group", "kind", "distance", "path", "size_bytes", "size_human", "width", "height", "duration_ms"}
+	DirDupeColumns = []string{"group", "hash", "path", "size_bytes", "size_human", "files"}
+	UndoColumns    = []string{"id", "time", "action", "source", "from", "to", "error"}
+)
+
+// HeavyRow is one file of a heavy report. Unknown times are left empty.
+func HeavyRow(path string, size int64, mtime, atime time.Time) []any {
+	return []any{path, size, ui.HumanBytes(size), Time(mtime), Time(atime)}
+}
+
+// Time formats t as RFC 3339, or "" for the zero time.
+func Time(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// ExtRow is one extension of an ext report; share is its fraction of total (0..1).
+func ExtRow(ext string, count int, size, total int64) []any {
+	share := 0.0
+	if total > 0 {
+		share = math.Round(float64(size)/float64(total)*10000) / 10000
+	}
+	return []any{ext, count, size, ui.HumanBytes(size), share}
+}
+
+// DupeRow is one copy within a duplicate group; group numbers start at 1.
+func DupeRow(group int, hash, path string, size int64, mtime time.Time) []any {
+	return []any{group, hash, path, size, ui.HumanBytes(size), Time(mtime)}
+}
+
+// DedupeRow is one replaced (or planned, skipped, failed) duplicate copy.
+func DedupeRow(action, method, path, keep string, size, reclaimed int64, err error) []any {
+	errText := ""
+	if err != nil {
+		errText = err.Error()
+	}
+	return []any{action, method, path, keep, size, ui.HumanBytes(size), reclaimed, errText}
+}
+
+// SimilarRow is one member of a similarity group; distance is to the group's first file.
+func SimilarRow(group int, kind string, distance int, path string, size int64, width, height int, duration time.Duration) []any {
+	return []any{group, kind, distance, path, size, ui.HumanBytes(size), width, height, duration.Milliseconds()}
+}
+
+// DirDupeRow is one folder within a group of identical folders; size and files cover its tree.
+func DirDupeRow(group int, hash, path string, size int64, files int) []any {
+	return []any{group, hash, path, size, ui.HumanBytes(size), files}
+}
+
+// SizeFields are the summary fields for a byte total.
+func SizeFields(key string, size int64) []Field {
+	return []Field{{key + "_bytes", size}, {key + "_human", ui.HumanBytes(size)}}
+}
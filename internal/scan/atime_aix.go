@@ -0,0 +1,9 @@
+//go:build aix
+
+package scan
+
+import "syscall"
+
+func accessTime(st *syscall.Stat_t) int64 {
+	return st.Atim.Sec*1e9 + int64(st.Atim.Nsec)
+}
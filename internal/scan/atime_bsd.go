@@ -0,0 +1,9 @@
+//go:build darwin || freebsd || netbsd
+
+package scan
+
+import "syscall"
+
+func accessTime(st *syscall.Stat_t) int64 {
+	return st.Atimespec.Nano()
+}
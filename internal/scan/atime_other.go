@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package scan
+
+import "os"
+
+// accessTime falls back to the modification time where access times are not exposed.
+func accessTime(_ os.FileInfo, mtime int64) int64 {
+	return mtime
+}
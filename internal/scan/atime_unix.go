@@ -0,0 +1,9 @@
+//go:build linux || openbsd || dragonfly || solaris
+
+package scan
+
+import "syscall"
+
+func accessTime(st *syscall.Stat_t) int64 {
+	return st.Atim.Nano()
+}
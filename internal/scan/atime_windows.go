@@ -0,0 +1,14 @@
+package scan
+
+import (
+	"os"
+	"syscall"
+)
+
+// accessTime reads the last access time; NTFS may update it lazily or not at all.
+func accessTime(info os.FileInfo, mtime int64) int64 {
+	if d, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return d.LastAccessTime.Nanoseconds()
+	}
+	return mtime
+}
@@ -0,0 +1,148 @@
+package scan
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirNode is one directory of a DirTree. Size, Files and Dirs cover the whole subtree.
+type DirNode struct {
+	Name  string
+	Size  int64
+	Files int
+	Dirs  int
+	// Own is the size of the files directly inside this directory.
+	Own int64
+	// Largest is the biggest file anywhere below this directory.
+	Largest FileInfo
+	// Children are sorted by size, largest first.
+	Children []*DirNode
+
+	parent *DirNode
+}
+
+// Path returns the full path of the directory.
+func (n *DirNode) Path() string {
+	if n.parent == nil {
+		return n.Name
+	}
+	return fastJoin(n.parent.Path(), n.Name)
+}
+
+// LargestChild returns the biggest subdirectory or nil for a leaf.
+func (n *DirNode) LargestChild() *DirNode {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[0]
+}
+
+// DirTree is a complete in-memory directory tree built in one pass.
+type DirTree struct {
+	Root     *DirNode
+	TopFiles []FileInfo
+	ExtStats []ExtStatsItem
+	Sizes    SizeTotals
+	Seen     int
+	Limited  bool
+	Symlinks []Symlink
+	Errors   ScanErrors
+
+	byPath map[string]*DirNode
+}
+
+// Find returns the node for path, which may be absolute or relative to the tree root.
+func (t *DirTree) Find(path string) *DirNode {
+	if path == "" {
+		return t.Root
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(t.Root.Name, path)
+	}
+	return t.byPath[filepath.Clean(path)]
+}
+
+// ScanDirTreeContext walks root once and keeps the size of every directory, so any subtree
+// can be inspected afterwards without rescanning.
+func ScanDirTreeContext(ctx context.Context, root string, topN int, opts Options) (*DirTree, error) {
+	root = filepath.Clean(root)
+	r := newReducer(reduceConfig{root: root, mode: opts.SizeMode, topBy: opts.TopBy, topN: topN, byExt: true, byDir: true}, shardCount(opts, true))
+	res, err := walk(ctx, root, opts, true, r.add, r.addDir)
+	if err != nil {
+		return nil, err
+	}
+	acc := r.merge(false)
+	t := buildDirTree(root, acc.byDir)
+	t.TopFiles = acc.top.ListDesc()
+	t.ExtStats = sortedExtStats(acc.byExt)
+	t.Sizes = acc.sizes
+	t.Seen = res.seen
+	t.Limited = res.limited
+	t.Symlinks = res.symlinks
+	t.Errors = res.errors
+	return t, nil
+}
+
+func buildDirTree(root string, dirs map[string]*dirTotals) *DirTree {
+	t := &DirTree{
+		Root:   &DirNode{Name: root},
+		byPath: make(map[string]*DirNode, len(dirs)+1),
+	}
+	t.byPath[root] = t.Root
+	rootPrefix := root
+	if !strings.HasSuffix(rootPrefix, string(filepath.Separator)) {
+		rootPrefix += string(filepath.Separator)
+	}
+
+	var node func(path string) *DirNode
+	node = func(path string) *DirNode {
+		if n := t.byPath[path]; n != nil {
+			return n
+		}
+		if !strings.HasPrefix(path, rootPrefix) {
+			return t.Root
+		}
+		i := strings.LastIndexByte(path, filepath.Separator)
+		parentPath := root
+		if i >= len(root) {
+			parentPath = path[:i]
+		}
+		parent := node(parentPath)
+		n := &DirNode{Name: path[i+1:], parent: parent}
+		parent.Children = append(parent.Children, n)
+		t.byPath[path] = n
+		return n
+	}
+	for path, d := range dirs {
+		n := node(path)
+		n.Own += d.size
+		n.Files += d.files
+		if d.files > 0 && (n.Largest.Path == "" || d.largest.Size > n.Largest.Size) {
+			n.Largest = d.largest
+		}
+	}
+	sumDirNode(t.Root)
+	return t
+}
+
+// sumDirNode rolls subtree totals up into n and orders its children.
+func sumDirNode(n *DirNode) {
+	n.Size = n.Own
+	for _, c := range n.Children {
+		sumDirNode(c)
+		n.Size += c.Size
+		n.Files += c.Files
+		n.Dirs += c.Dirs + 1
+		if c.Largest.Path != "" && (n.Largest.Path == "" || c.Largest.Size > n.Largest.Size) {
+			n.Largest = c.Largest
+		}
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		if n.Children[i].Size == n.Children[j].Size {
+			return n.Children[i].Name < n.Children[j].Name
+		}
+		return n.Children[i].Size > n.Children[j].Size
+	})
+}
@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// ErrorKind classifies a per-path scan error.
+type ErrorKind int
+
+const (
+	// ErrDenied is a permission error.
+	ErrDenied ErrorKind = iota
+	// ErrVanished is a path removed between listing and reading it.
+	ErrVanished
+	// ErrIO is any other read failure.
+	ErrIO
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrDenied:
+		return "denied"
+	case ErrVanished:
+		return "vanished"
+	}
+	return "io"
+}
+
+// maxPathErrors caps PathError items kept per scan; the counters stay exact.
+const maxPathErrors = 200
+
+// PathError is a non-fatal error for one file or directory.
+type PathError struct {
+	Path string
+	Kind ErrorKind
+	Dir  bool
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	var fsErr *fs.PathError
+	if errors.As(e.Err, &fsErr) {
+		// fs errors already name the path.
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCount splits one error kind by the type of path it hit.
+type ErrorCount struct {
+	Dirs  int
+	Files int
+}
+
+// ScanErrors collects the per-path errors a scan skipped over.
+type ScanErrors struct {
+	Items    []PathError
+	Denied   ErrorCount
+	Vanished ErrorCount
+	IO       ErrorCount
+}
+
+// Total is the number of errors, including those beyond the Items cap.
+func (e ScanErrors) Total() int {
+	return e.Denied.Dirs + e.Denied.Files + e.Vanished.Dirs + e.Vanished.Files + e.IO.Dirs + e.IO.Files
+}
+
+// Summary renders the counters as e.g. "312 dirs unreadable, 2 files vanished"; it is empty without errors.
+func (e ScanErrors) Summary() string {
+	var parts []string
+	add := func(n int, what string) {
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, what))
+		}
+	}
+	add(e.Denied.Dirs, "dirs unreadable")
+	add(e.Denied.Files, "files unreadable")
+	add(e.Vanished.Dirs, "dirs vanished")
+	add(e.Vanished.Files, "files vanished")
+	add(e.IO.Dirs, "dirs with I/O errors")
+	add(e.IO.Files, "files with I/O errors")
+	return strings.Join(parts, ", ")
+}
+
+func (e *ScanErrors) add(pe PathError) {
+	c := &e.IO
+	switch pe.Kind {
+	case ErrDenied:
+		c = &e.Denied
+	case ErrVanished:
+		c = &e.Vanished
+	}
+	if pe.Dir {
+		c.Dirs++
+	} else {
+		c.Files++
+	}
+	if len(e.Items) < maxPathErrors {
+		e.Items = append(e.Items, pe)
+	}
+}
+
+func classifyError(err error) ErrorKind {
+	switch {
+	case isAccessDenied(err):
+		return ErrDenied
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrVanished
+	}
+	return ErrIO
+}
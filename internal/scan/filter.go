@@ -0,0 +1,245 @@
+package scan
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// FilterSpec describes which files a scan counts. Empty fields do not restrict anything.
+//
+// Include and Exclude take path patterns of three kinds:
+//   - "re:EXPR" is a regular expression matched against the slash-separated full path.
+//   - A pattern with *, ? or [ is a glob. ** spans folders, * and ? stay within one name.
+//     Without a slash it matches any single name in the path (e.g. "*.log", "build*");
+//     with one it matches a run of whole names (e.g. "src/*/testdata", "/home/*/Downloads").
+//     A glob matching a folder matches everything below it.
+//   - Anything else matches when the path contains it, ignoring case.
+//
+// Extensions are compared lower-cased with the leading dot; "(no_ext)" selects files without one.
+type FilterSpec struct {
+	Include    []string
+	Exclude    []string
+	IncludeExt []string
+	ExcludeExt []string
+	// MinSize and MaxSize bound the file size in the scan's SizeMode; 0 means no bound.
+	MinSize int64
+	MaxSize int64
+	// ModifiedAfter and ModifiedBefore bound the modification time; the zero time means no bound.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+}
+
+// Filter is a compiled FilterSpec. A nil *Filter matches every file.
+type Filter struct {
+	include    []pathPattern
+	exclude    []pathPattern
+	includeExt map[string]bool
+	excludeExt map[string]bool
+	minSize    int64
+	maxSize    int64
+	after      time.Time
+	before     time.Time
+}
+
+// NewFilter compiles spec. It returns nil for a spec that does not restrict anything.
+func NewFilter(spec FilterSpec) (*Filter, error) {
+	f := &Filter{
+		includeExt: extSet(spec.IncludeExt),
+		excludeExt: extSet(spec.ExcludeExt),
+		minSize:    spec.MinSize,
+		maxSize:    spec.MaxSize,
+		after:      spec.ModifiedAfter,
+		before:     spec.ModifiedBefore,
+	}
+	var err error
+	if f.include, err = compilePatterns(spec.Include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compilePatterns(spec.Exclude); err != nil {
+		return nil, err
+	}
+	if len(f.include) == 0 && len(f.exclude) == 0 && len(f.includeExt) == 0 && len(f.excludeExt) == 0 &&
+		f.minSize <= 0 && f.maxSize <= 0 && f.after.IsZero() && f.before.IsZero() {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Match reports whether a file with the given size and modification time passes the filter.
+func (f *Filter) Match(path string, size int64, mtime time.Time) bool {
+	if f == nil {
+		return true
+	}
+	if f.minSize > 0 && size < f.minSize {
+		return false
+	}
+	if f.maxSize > 0 && size > f.maxSize {
+		return false
+	}
+	if !f.after.IsZero() && mtime.Before(f.after) {
+		return false
+	}
+	if !f.before.IsZero() && !mtime.Before(f.before) {
+		return false
+	}
+	return f.MatchPath(path)
+}
+
+// MatchPath applies only the path and extension rules.
+func (f *Filter) MatchPath(path string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.includeExt) > 0 || len(f.excludeExt) > 0 {
+		ext := fastLowerExt(path)
+		if len(f.includeExt) > 0 && !f.includeExt[ext] {
+			return false
+		}
+		if f.excludeExt[ext] {
+			return false
+		}
+	}
+	if len(f.include) == 0 && len(f.exclude) == 0 {
+		return true
+	}
+	p := newFilterPath(path)
+	if len(f.include) > 0 && !matchAny(f.include, p) {
+		return false
+	}
+	return !matchAny(f.exclude, p)
+}
+
+// filterPath is a path in the forms patterns are matched against, built lazily.
+type filterPath struct {
+	slash string
+	lower string
+}
+
+func newFilterPath(path string) *filterPath {
+	return &filterPath{slash: filepath.ToSlash(path)}
+}
+
+func (p *filterPath) lowered() string {
+	if p.lower == "" {
+		p.lower = strings.ToLower(p.slash)
+	}
+	return p.lower
+}
+
+type pathPattern struct {
+	re     *regexp.Regexp
+	substr string
+}
+
+func (pat pathPattern) match(p *filterPath) bool {
+	if pat.re != nil {
+		return pat.re.MatchString(p.slash)
+	}
+	return strings.Contains(p.lowered(), pat.substr)
+}
+
+func matchAny(pats []pathPattern, p *filterPath) bool {
+	for _, pat := range pats {
+		if pat.match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilePatterns(raw []string) ([]pathPattern, error) {
+	var out []pathPattern
+	for _, s := range raw {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		pat, err := compilePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pat)
+	}
+	return out, nil
+}
+
+func compilePattern(s string) (pathPattern, error) {
+	if expr, ok := strings.CutPrefix(s, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return pathPattern{}, fmt.Errorf("bad regex %q: %w", expr, err)
+		}
+		return pathPattern{re: re}, nil
+	}
+	s = filepath.ToSlash(strings.TrimSpace(s))
+	if !strings.ContainsAny(s, "*?[") {
+		return pathPattern{substr: strings.ToLower(s)}, nil
+	}
+	re, err := regexp.Compile(globRegexp(s))
+	if err != nil {
+		return pathPattern{}, fmt.Errorf("bad glob %q: %w", s, err)
+	}
+	return pathPattern{re: re}, nil
+}
+
+// globRegexp translates a glob into a regular expression that matches whole path names.
+func globRegexp(glob string) string {
+	var b strings.Builder
+	if runtime.GOOS == "windows" {
+		b.WriteString("(?i)")
+	}
+	if strings.HasPrefix(glob, "/") || filepath.VolumeName(glob) != "" {
+		b.WriteString("^")
+	} else {
+		b.WriteString("(?:^|/)")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("(?:/|$)")
+	return b.String()
+}
+
+func extSet(exts []string) map[string]bool {
+	set := map[string]bool{}
+	for _, raw := range exts {
+		for _, ext := range strings.Split(raw, ",") {
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if ext == "" {
+				continue
+			}
+			if ext != "(no_ext)" && !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			set[ext] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,195 @@
+package scan
+
+import (
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"icicle/internal/appdir"
+)
+
+const indexVersion = 4
+
+const (
+	entryFile uint8 = iota
+	entryDir
+	entryLink
+)
+
+// indexEntry is one child of an indexed directory, kept in ReadDir order.
+// Dev and Ino are only set for files with several hard links. ATime is the access time at
+// the last read of the directory; replayed entries do not see later reads.
+type indexEntry struct {
+	Name    string
+	Size    int64
+	Alloc   int64
+	ModTime int64
+	ATime   int64
+	Kind    uint8
+	Dev     uint64
+	Ino     uint64
+}
+
+type indexDir struct {
+	ModTime int64
+	Entries []indexEntry
+}
+
+type indexFile struct {
+	Version int
+	Root    string
+	Dirs    map[string]*indexDir
+}
+
+// Index is a persistent per-root listing of directories and file sizes.
+// When Options.Index is set, directories whose mtime did not change since the last
+// scan are replayed from the index instead of being read again. A directory mtime
+// only moves when entries are added, removed or renamed, so a file that grew in place
+// keeps its old size until its directory changes.
+// An Index must not be shared by concurrent scans.
+type Index struct {
+	root string
+	file string
+
+	mu   sync.Mutex
+	dirs map[string]*indexDir
+	next map[string]*indexDir
+}
+
+// IndexPath returns where the index for root is stored under the icicle config dir.
+func IndexPath(root string) (string, error) {
+	dir, err := appdir.Dir("index")
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(filepath.Clean(root)))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".gob"), nil
+}
+
+// OpenIndex loads the index for root. A missing or outdated index file yields an empty index.
+func OpenIndex(root string) (*Index, error) {
+	root = filepath.Clean(root)
+	file, err := IndexPath(root)
+	if err != nil {
+		return nil, err
+	}
+	x := &Index{
+		root: root,
+		file: file,
+		dirs: map[string]*indexDir{},
+		next: map[string]*indexDir{},
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var data indexFile
+	if err := gob.NewDecoder(f).Decode(&data); err != nil || data.Version != indexVersion || data.Root != root {
+		// Corrupt or foreign files are rebuilt by the next scan.
+		return x, nil
+	}
+	if data.Dirs != nil {
+		x.dirs = data.Dirs
+	}
+	return x, nil
+}
+
+// Root is the directory the index was opened for.
+func (x *Index) Root() string {
+	return x.root
+}
+
+// Len returns the number of indexed directories.
+func (x *Index) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.dirs)
+}
+
+// Walk calls onFile for every indexed file without touching the disk.
+// Hard links are reported under each of their names.
+func (x *Index) Walk(mode SizeMode, onFile func(path string, size int64)) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for dir, d := range x.dirs {
+		for _, e := range d.Entries {
+			if e.Kind == entryFile {
+				onFile(fastJoin(dir, e.Name), fileStat{apparent: e.Size, allocated: e.Alloc}.pick(mode))
+			}
+		}
+	}
+}
+
+// Save writes the index atomically next to its previous version.
+func (x *Index) Save() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	tmp, err := os.CreateTemp(filepath.Dir(x.file), ".index-*.tmp")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(tmp).Encode(indexFile{Version: indexVersion, Root: x.root, Dirs: x.dirs})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), x.file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// lookup returns the cached listing of dir if its mtime is unchanged and marks it as visited.
+func (x *Index) lookup(dir string, mtime time.Time) *indexDir {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	d := x.dirs[dir]
+	if d == nil || d.ModTime != mtime.UnixNano() {
+		return nil
+	}
+	x.next[dir] = d
+	return d
+}
+
+func (x *Index) store(dir string, d *indexDir) {
+	x.mu.Lock()
+	x.next[dir] = d
+	x.mu.Unlock()
+}
+
+// commit folds the directories visited by a walk of root into the index.
+// Only a complete walk may drop directories it did not see.
+func (x *Index) commit(root string, complete bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if complete {
+		prefix := root
+		if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+			prefix += string(filepath.Separator)
+		}
+		for dir := range x.dirs {
+			if dir != root && !strings.HasPrefix(dir, prefix) {
+				continue
+			}
+			if _, ok := x.next[dir]; !ok {
+				delete(x.dirs, dir)
+			}
+		}
+	}
+	for dir, d := range x.next {
+		x.dirs[dir] = d
+	}
+	x.next = map[string]*indexDir{}
+}
@@ -0,0 +1,196 @@
+package scan
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reduceConfig selects which aggregates a reducer keeps.
+type reduceConfig struct {
+	root     string
+	topN     int
+	byChild  bool
+	byExt    bool
+	byDir    bool
+	mode     SizeMode
+	topBy    TopOrder
+	now      time.Time // reference time for TopByAgeWeighted
+	rootName string    // ByChild key for files directly under root; empty keeps them in RootFiles
+}
+
+// accumulator is the per-shard state of a reducer. Only the goroutine owning the shard touches it,
+// except for streaming snapshots which take mu.
+type accumulator struct {
+	mu        sync.Mutex
+	seen      int
+	total     int64
+	sizes     SizeTotals
+	rootFiles int64
+	top       *TopFiles
+	byChild   map[string]int64
+	byExt     map[string]ExtStatsItem
+	byDir     map[string]*dirTotals
+}
+
+// dirTotals are the direct (non-recursive) file totals of one directory.
+type dirTotals struct {
+	size    int64
+	files   int
+	largest FileInfo
+}
+
+// reducer fans file callbacks out to one accumulator per walker shard and merges them at the end.
+// This keeps the hot path free of a shared lock.
+type reducer struct {
+	cfg        reduceConfig
+	rootPrefix string
+	shards     []*accumulator
+}
+
+func newReducer(cfg reduceConfig, shards int) *reducer {
+	cfg.root = filepath.Clean(cfg.root)
+	if cfg.now.IsZero() {
+		cfg.now = time.Now()
+	}
+	rootPrefix := cfg.root
+	if !strings.HasSuffix(rootPrefix, string(filepath.Separator)) {
+		rootPrefix += string(filepath.Separator)
+	}
+	r := &reducer{cfg: cfg, rootPrefix: rootPrefix, shards: make([]*accumulator, shards)}
+	for i := range r.shards {
+		r.shards[i] = r.newAccumulator()
+	}
+	return r
+}
+
+func (r *reducer) newAccumulator() *accumulator {
+	acc := &accumulator{top: NewTopFilesBy(r.cfg.topN, r.cfg.topBy, r.cfg.now)}
+	if r.cfg.byChild {
+		acc.byChild = map[string]int64{}
+	}
+	if r.cfg.byExt {
+		acc.byExt = map[string]ExtStatsItem{}
+	}
+	if r.cfg.byDir {
+		acc.byDir = map[string]*dirTotals{}
+	}
+	return acc
+}
+
+// add is a shardFunc; it must only be called by the goroutine owning shard.
+func (r *reducer) add(shard int, path string, size fileStat) {
+	r.addTo(r.shards[shard], path, size)
+}
+
+// addDir records a directory even if no file below it is ever seen.
+func (r *reducer) addDir(shard int, dir string) {
+	acc := r.shards[shard]
+	if _, ok := acc.byDir[dir]; !ok {
+		acc.byDir[dir] = &dirTotals{}
+	}
+}
+
+// addLocked is add for reducers that are snapshotted while the walk runs.
+func (r *reducer) addLocked(shard int, path string, size fileStat) {
+	acc := r.shards[shard]
+	acc.mu.Lock()
+	r.addTo(acc, path, size)
+	acc.mu.Unlock()
+}
+
+func (r *reducer) addTo(acc *accumulator, path string, fs fileStat) {
+	size := fs.pick(r.cfg.mode)
+	acc.seen++
+	acc.total += size
+	acc.sizes.Apparent += fs.apparent
+	acc.sizes.Allocated += fs.allocated
+	if r.cfg.topN > 0 {
+		acc.top.Push(fs.info(path, r.cfg.mode))
+	}
+	if acc.byChild != nil {
+		rel := path
+		if strings.HasPrefix(path, r.rootPrefix) {
+			rel = path[len(r.rootPrefix):]
+		}
+		child := firstPathSegment(rel)
+		switch {
+		case child != "" && child != rel:
+			acc.byChild[child] += size
+		case r.cfg.rootName != "":
+			acc.byChild[r.cfg.rootName] += size
+		default:
+			acc.rootFiles += size
+		}
+	}
+	if acc.byExt != nil {
+		ext := fastLowerExt(path)
+		cur := acc.byExt[ext]
+		cur.Ext = ext
+		cur.Count++
+		cur.Size += size
+		acc.byExt[ext] = cur
+	}
+	if acc.byDir != nil {
+		dir := r.cfg.root
+		if i := strings.LastIndexByte(path, filepath.Separator); i >= len(r.cfg.root) {
+			dir = path[:i]
+		}
+		d := acc.byDir[dir]
+		if d == nil {
+			d = &dirTotals{}
+			acc.byDir[dir] = d
+		}
+		d.size += size
+		d.files++
+		if size > d.largest.Size || d.files == 1 {
+			d.largest = fs.info(path, r.cfg.mode)
+		}
+	}
+}
+
+// merge folds every shard into a fresh accumulator. With locked set it takes each shard's mutex,
+// which makes it safe to call while the walk is still running.
+func (r *reducer) merge(locked bool) *accumulator {
+	out := r.newAccumulator()
+	for _, acc := range r.shards {
+		if locked {
+			acc.mu.Lock()
+		}
+		out.seen += acc.seen
+		out.total += acc.total
+		out.sizes.Apparent += acc.sizes.Apparent
+		out.sizes.Allocated += acc.sizes.Allocated
+		out.rootFiles += acc.rootFiles
+		for _, rf := range acc.top.h {
+			out.top.Push(rf.FileInfo)
+		}
+		for k, v := range acc.byChild {
+			out.byChild[k] += v
+		}
+		for k, v := range acc.byExt {
+			cur := out.byExt[k]
+			cur.Ext = k
+			cur.Count += v.Count
+			cur.Size += v.Size
+			out.byExt[k] = cur
+		}
+		for k, v := range acc.byDir {
+			cur := out.byDir[k]
+			if cur == nil {
+				cur = &dirTotals{}
+				out.byDir[k] = cur
+			}
+			if v.files > 0 && (cur.files == 0 || v.largest.Size > cur.largest.Size) {
+				cur.largest = v.largest
+			}
+			cur.size += v.size
+			cur.files += v.files
+		}
+		if locked {
+			acc.mu.Unlock()
+		}
+	}
+	return out
+}
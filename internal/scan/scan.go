@@ -2,33 +2,88 @@ package scan
 
 import (
 	"container/heap"
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"runtime"
 	"sort"
-	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
+	"time"
 )
 
 type FileInfo struct {
-	Path string
-	Size int64
+	Path       string
+	Size       int64
+	ModTime    time.Time
+	AccessTime time.Time
 }
 
-var errStopWalk = fmt.Errorf("scan stop")
+// Symlink is a symbolic link met during a walk.
+type Symlink struct {
+	Path   string
+	Target string
+	// Followed is set when the link target was counted.
+	Followed bool
+	// Reason says why a link was not followed: "not followed", "dangling",
+	// "target inside root", "already walked" or "already counted".
+	Reason string
+}
+
+// maxSymlinks caps the links kept per scan; trees like pnpm stores can hold millions.
+const maxSymlinks = 10000
+
+// TopOrder decides which files a top-N list keeps and how it is sorted.
+type TopOrder int
+
+const (
+	// TopBySize keeps the largest files.
+	TopBySize TopOrder = iota
+	// TopByModTime keeps the files modified longest ago.
+	TopByModTime
+	// TopByAgeWeighted ranks by size × (1 + days since modification), so big stale files
+	// come before equally big fresh ones.
+	TopByAgeWeighted
+)
+
+// ParseTopOrder accepts "size", "mtime" or "age-weighted".
+func ParseTopOrder(s string) (TopOrder, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "size":
+		return TopBySize, nil
+	case "mtime", "age":
+		return TopByModTime, nil
+	case "age-weighted", "weighted":
+		return TopByAgeWeighted, nil
+	}
+	return TopBySize, fmt.Errorf("unknown sort %q (use size, mtime or age-weighted)", s)
+}
+
+func (o TopOrder) String() string {
+	switch o {
+	case TopByModTime:
+		return "mtime"
+	case TopByAgeWeighted:
+		return "age-weighted"
+	}
+	return "size"
+}
 
 type TopFiles struct {
 	max int
+	by  TopOrder
+	now time.Time
 	h   fileHeap
 }
 
 func NewTopFiles(max int) *TopFiles {
-	return &TopFiles{max: max, h: fileHeap{}}
+	return NewTopFilesBy(max, TopBySize, time.Time{})
+}
+
+// NewTopFilesBy keeps the max files ranked highest by order; now is the reference for file ages.
+func NewTopFilesBy(max int, by TopOrder, now time.Time) *TopFiles {
+	return &TopFiles{max: max, by: by, now: now, h: fileHeap{}}
 }
 
 func (t *TopFiles) Push(fi FileInfo) {
@@ -37,29 +92,55 @@ func (t *TopFiles) Push(fi FileInfo) {
 	if t.max <= 0 {
 		return
 	}
+	rf := rankedFile{FileInfo: fi, key: t.rank(fi)}
 	if t.h.Len() < t.max {
-		heap.Push(&t.h, fi)
+		heap.Push(&t.h, rf)
 		return
 	}
-	if t.h[0].Size < fi.Size {
-		heap.Pop(&t.h)
-		heap.Push(&t.h, fi)
+	if t.h[0].key < rf.key {
+		// Replace the minimum in place; avoids the interface boxing of Pop+Push on the hot path.
+		t.h[0] = rf
+		heap.Fix(&t.h, 0)
+	}
+}
+
+func (t *TopFiles) rank(fi FileInfo) float64 {
+	switch t.by {
+	case TopByModTime:
+		return -float64(fi.ModTime.UnixNano())
+	case TopByAgeWeighted:
+		days := t.now.Sub(fi.ModTime).Hours() / 24
+		if days < 0 {
+			days = 0
+		}
+		return float64(fi.Size) * (1 + days)
 	}
+	return float64(fi.Size)
 }
 
+// ListDesc returns the kept files, highest ranked first.
 func (t *TopFiles) ListDesc() []FileInfo {
-	out := make([]FileInfo, t.h.Len())
-	copy(out, t.h)
-	sort.Slice(out, func(i, j int) bool { return out[i].Size > out[j].Size })
+	ranked := make([]rankedFile, t.h.Len())
+	copy(ranked, t.h)
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].key > ranked[j].key })
+	out := make([]FileInfo, len(ranked))
+	for i, rf := range ranked {
+		out[i] = rf.FileInfo
+	}
 	return out
 }
 
-type fileHeap []FileInfo
+type rankedFile struct {
+	FileInfo
+	key float64
+}
+
+type fileHeap []rankedFile
 
 func (h fileHeap) Len() int            { return len(h) }
-func (h fileHeap) Less(i, j int) bool  { return h[i].Size < h[j].Size }
+func (h fileHeap) Less(i, j int) bool  { return h[i].key < h[j].key }
 func (h fileHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
-func (h *fileHeap) Push(x interface{}) { *h = append(*h, x.(FileInfo)) }
+func (h *fileHeap) Push(x interface{}) { *h = append(*h, x.(rankedFile)) }
 func (h *fileHeap) Pop() interface{} {
 	old := *h
 	n := len(old)
@@ -68,106 +149,6 @@ func (h *fileHeap) Pop() interface{} {
 	return x
 }
 
-// WalkAll walks the path and calls onFile for each file found.
-func WalkAll(root string, onFile func(path string, size int64)) error {
-	root = filepath.Clean(root)
-	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			if isAccessDenied(err) {
-				// Windows system folders like $Recycle.Bin are often unreadable for normal users.
-				if d != nil && d.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-			return err
-		}
-		if shouldSkipDirByName(d) {
-			return filepath.SkipDir
-		}
-		if d.Type()&os.ModeSymlink != 0 {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		info, err := d.Info()
-		if err != nil {
-			if isAccessDenied(err) {
-				return nil
-			}
-			return err
-		}
-		onFile(path, info.Size())
-		return nil
-	})
-}
-
-// WalkAllLimit walks files up to maxFiles and then stops gracefully.
-func WalkAllLimit(root string, maxFiles int, onFile func(path string, size int64)) (int, error) {
-	if maxFiles <= 0 {
-		err := WalkAll(root, onFile)
-		return 0, err
-	}
-	root = filepath.Clean(root)
-	count := 0
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			if isAccessDenied(err) {
-				if d != nil && d.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-			return err
-		}
-		if shouldSkipDirByName(d) {
-			return filepath.SkipDir
-		}
-		if d.Type()&os.ModeSymlink != 0 {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		info, err := d.Info()
-		if err != nil {
-			if isAccessDenied(err) {
-				return nil
-			}
-			return err
-		}
-		onFile(path, info.Size())
-		count++
-		if count >= maxFiles {
-			return errStopWalk
-		}
-		return nil
-	})
-	if err != nil && !errors.Is(err, errStopWalk) {
-		return count, err
-	}
-	return count, nil
-}
-
-func shouldSkipDirByName(d fs.DirEntry) bool {
-	if d == nil || !d.IsDir() {
-		return false
-	}
-	return shouldSkipDirName(d.Name())
-}
-
-func shouldSkipDirName(name string) bool {
-	name = strings.ToLower(strings.TrimSpace(name))
-	return name == "$recycle.bin" || name == "system volume information"
-}
-
 func isAccessDenied(err error) bool {
 	if os.IsPermission(err) {
 		return true
@@ -188,16 +169,26 @@ func isAccessDenied(err error) bool {
 type TreeStats struct {
 	Root       string
 	Total      int64
+	Sizes      SizeTotals
 	ByChild    map[string]int64
 	TopFiles   []FileInfo
 	RootFiles  int64
 	ChildNames []string
+	Seen       int
+	Limited    bool
+	Symlinks   []Symlink
+	Errors     ScanErrors
 }
 
 type HeavyStats struct {
 	Root     string
 	Total    int64
+	Sizes    SizeTotals
 	TopFiles []FileInfo
+	Seen     int
+	Limited  bool
+	Symlinks []Symlink
+	Errors   ScanErrors
 }
 
 type ExtStatsItem struct {
@@ -206,131 +197,30 @@ type ExtStatsItem struct {
 	Size  int64
 }
 
+type ExtStats struct {
+	Root     string
+	Total    int64
+	Sizes    SizeTotals
+	Items    []ExtStatsItem
+	Seen     int
+	Limited  bool
+	Symlinks []Symlink
+	Errors   ScanErrors
+}
+
 type OverviewStats struct {
 	Root     string
 	Total    int64
+	Sizes    SizeTotals
 	Seen     int
 	Limited  bool
+	Symlinks []Symlink
+	Errors   ScanErrors
 	ByChild  map[string]int64
 	TopFiles []FileInfo
 	ExtStats []ExtStatsItem
 }
 
-func walkFilesConcurrent(root string, maxFiles int, onFile func(path string, size int64)) (int, bool, error) {
-	root = filepath.Clean(root)
-	workers := scanWorkers()
-	sem := make(chan struct{}, workers)
-	var wg sync.WaitGroup
-	var seen atomic.Int64
-	var stop atomic.Bool
-	var firstErr error
-	var errMu sync.Mutex
-
-	setErr := func(err error) {
-		if err == nil {
-			return
-		}
-		errMu.Lock()
-		if firstErr == nil {
-			firstErr = err
-		}
-		errMu.Unlock()
-		stop.Store(true)
-	}
-
-	var walkDir func(dir string)
-	walkDir = func(dir string) {
-		if stop.Load() {
-			return
-		}
-		entries, err := os.ReadDir(dir)
-		if err != nil {
-			if !isAccessDenied(err) {
-				setErr(err)
-			}
-			return
-		}
-		for _, e := range entries {
-			if stop.Load() {
-				return
-			}
-			name := e.Name()
-			full := fastJoin(dir, name)
-			t := e.Type()
-			if t&os.ModeSymlink != 0 {
-				continue
-			}
-			if e.IsDir() {
-				if shouldSkipDirName(name) {
-					continue
-				}
-				// Try to process subdir in parallel; fallback to inline walk to avoid deadlocks.
-				select {
-				case sem <- struct{}{}:
-					wg.Add(1)
-					go func(p string) {
-						defer func() {
-							<-sem
-							wg.Done()
-						}()
-						walkDir(p)
-					}(full)
-				default:
-					walkDir(full)
-				}
-				continue
-			}
-			info, err := e.Info()
-			if err != nil {
-				if !isAccessDenied(err) {
-					setErr(err)
-				}
-				continue
-			}
-			onFile(full, info.Size())
-			n := int(seen.Add(1))
-			if maxFiles > 0 && n >= maxFiles {
-				stop.Store(true)
-				return
-			}
-		}
-	}
-
-	// Root scan runs in current goroutine; spawned subdir goroutines are tracked via WaitGroup.
-	walkDir(root)
-	wg.Wait()
-
-	errMu.Lock()
-	err := firstErr
-	errMu.Unlock()
-	count := int(seen.Load())
-	limited := maxFiles > 0 && count >= maxFiles
-	return count, limited, err
-}
-
-func scanWorkers() int {
-	// IO-bound scanning benefits from higher concurrency than CPU count.
-	workers := runtime.NumCPU() * 2
-	if workers < 8 {
-		workers = 8
-	}
-	if workers > 32 {
-		workers = 32
-	}
-	if raw := strings.TrimSpace(os.Getenv("ICICLE_SCAN_WORKERS")); raw != "" {
-		if n, err := strconv.Atoi(raw); err == nil {
-			if n < 1 {
-				n = 1
-			}
-			if n > 128 {
-				n = 128
-			}
-			workers = n
-		}
-	}
-	return workers
-}
-
 func fastJoin(dir, name string) string {
 	if dir == "" {
 		return name
@@ -378,212 +268,150 @@ func fastLowerExt(path string) string {
 }
 
 func ScanTopFiles(root string, topN int) (*HeavyStats, error) {
-	root = filepath.Clean(root)
-	stats := &HeavyStats{Root: root}
-	top := NewTopFiles(topN)
-	var mu sync.Mutex
-	_, _, err := walkFilesConcurrent(root, 0, func(path string, size int64) {
-		mu.Lock()
-		stats.Total += size
-		top.Push(FileInfo{Path: path, Size: size})
-		mu.Unlock()
-	})
-	if err != nil {
-		return nil, err
-	}
-	stats.TopFiles = top.ListDesc()
-	return stats, nil
+	return ScanTopFilesContext(context.Background(), root, topN, Options{})
 }
 
 func ScanTopFilesLimited(root string, topN int, maxFiles int) (*HeavyStats, int, bool, error) {
-	root = filepath.Clean(root)
-	stats := &HeavyStats{Root: root}
-	top := NewTopFiles(topN)
-	var mu sync.Mutex
-	seen, limited, err := walkFilesConcurrent(root, maxFiles, func(path string, size int64) {
-		mu.Lock()
-		stats.Total += size
-		top.Push(FileInfo{Path: path, Size: size})
-		mu.Unlock()
-	})
+	stats, err := ScanTopFilesContext(context.Background(), root, topN, Options{MaxFiles: maxFiles})
 	if err != nil {
-		return nil, seen, false, err
+		return nil, 0, false, err
 	}
-	stats.TopFiles = top.ListDesc()
-	return stats, seen, limited, nil
+	return stats, stats.Seen, stats.Limited, nil
 }
 
-func ScanTree(root string, topN int) (*TreeStats, error) {
+// ScanTopFilesContext collects the topN largest files under root.
+// The scan stops with ctx.Err() once ctx is cancelled.
+func ScanTopFilesContext(ctx context.Context, root string, topN int, opts Options) (*HeavyStats, error) {
 	root = filepath.Clean(root)
-	stats := &TreeStats{Root: root, ByChild: map[string]int64{}}
-	top := NewTopFiles(topN)
-	rootPrefix := root
-	if !strings.HasSuffix(rootPrefix, string(filepath.Separator)) {
-		rootPrefix += string(filepath.Separator)
-	}
-	var mu sync.Mutex
-	_, _, err := walkFilesConcurrent(root, 0, func(path string, size int64) {
-		mu.Lock()
-		stats.Total += size
-		rel := path
-		if strings.HasPrefix(path, rootPrefix) {
-			rel = path[len(rootPrefix):]
-		}
-		if rel == "" {
-			stats.RootFiles += size
-		} else {
-			child := firstPathSegment(rel)
-			if child == rel || child == "" {
-				stats.RootFiles += size
-			} else {
-				stats.ByChild[child] += size
-			}
-		}
-		top.Push(FileInfo{Path: path, Size: size})
-		mu.Unlock()
-	})
+	r := newReducer(reduceConfig{root: root, mode: opts.SizeMode, topBy: opts.TopBy, topN: topN}, shardCount(opts, true))
+	res, err := walkFiles(ctx, root, opts, true, r.add)
 	if err != nil {
 		return nil, err
 	}
-	stats.ChildNames = make([]string, 0, len(stats.ByChild))
-	for name := range stats.ByChild {
-		stats.ChildNames = append(stats.ChildNames, name)
-	}
-	sort.Slice(stats.ChildNames, func(i, j int) bool {
-		return stats.ByChild[stats.ChildNames[i]] > stats.ByChild[stats.ChildNames[j]]
-	})
-	stats.TopFiles = top.ListDesc()
-	return stats, nil
+	acc := r.merge(false)
+	return &HeavyStats{
+		Root:     root,
+		Total:    acc.total,
+		Sizes:    acc.sizes,
+		TopFiles: acc.top.ListDesc(),
+		Seen:     res.seen,
+		Limited:  res.limited,
+		Symlinks: res.symlinks,
+		Errors:   res.errors,
+	}, nil
+}
+
+func ScanTree(root string, topN int) (*TreeStats, error) {
+	return ScanTreeContext(context.Background(), root, topN, Options{})
 }
 
 func ScanTreeLimited(root string, topN int, maxFiles int) (*TreeStats, int, bool, error) {
-	root = filepath.Clean(root)
-	stats := &TreeStats{Root: root, ByChild: map[string]int64{}}
-	top := NewTopFiles(topN)
-	rootPrefix := root
-	if !strings.HasSuffix(rootPrefix, string(filepath.Separator)) {
-		rootPrefix += string(filepath.Separator)
-	}
-	var mu sync.Mutex
-	seen, limited, err := walkFilesConcurrent(root, maxFiles, func(path string, size int64) {
-		mu.Lock()
-		stats.Total += size
-		rel := path
-		if strings.HasPrefix(path, rootPrefix) {
-			rel = path[len(rootPrefix):]
-		}
-		if rel == "" {
-			stats.RootFiles += size
-		} else {
-			child := firstPathSegment(rel)
-			if child == rel || child == "" {
-				stats.RootFiles += size
-			} else {
-				stats.ByChild[child] += size
-			}
-		}
-		top.Push(FileInfo{Path: path, Size: size})
-		mu.Unlock()
-	})
+	stats, err := ScanTreeContext(context.Background(), root, topN, Options{MaxFiles: maxFiles})
 	if err != nil {
-		return nil, seen, false, err
-	}
-	stats.ChildNames = make([]string, 0, len(stats.ByChild))
-	for name := range stats.ByChild {
-		stats.ChildNames = append(stats.ChildNames, name)
+		return nil, 0, false, err
 	}
-	sort.Slice(stats.ChildNames, func(i, j int) bool {
-		return stats.ByChild[stats.ChildNames[i]] > stats.ByChild[stats.ChildNames[j]]
-	})
-	stats.TopFiles = top.ListDesc()
-	return stats, seen, limited, nil
+	return stats, stats.Seen, stats.Limited, nil
 }
 
-func ScanExtStatsLimited(root string, maxFiles int) ([]ExtStatsItem, int, bool, error) {
+// ScanTreeContext aggregates sizes by first-level child of root and keeps the topN files.
+// The scan stops with ctx.Err() once ctx is cancelled.
+func ScanTreeContext(ctx context.Context, root string, topN int, opts Options) (*TreeStats, error) {
 	root = filepath.Clean(root)
-	byExt := map[string]ExtStatsItem{}
-	var mu sync.Mutex
-	seen, limited, err := walkFilesConcurrent(root, maxFiles, func(path string, size int64) {
-		ext := fastLowerExt(path)
-		mu.Lock()
-		cur := byExt[ext]
-		cur.Ext = ext
-		cur.Count++
-		cur.Size += size
-		byExt[ext] = cur
-		mu.Unlock()
-	})
+	r := newReducer(reduceConfig{root: root, mode: opts.SizeMode, topBy: opts.TopBy, topN: topN, byChild: true}, shardCount(opts, true))
+	res, err := walkFiles(ctx, root, opts, true, r.add)
 	if err != nil {
-		return nil, seen, limited, err
+		return nil, err
 	}
-	out := make([]ExtStatsItem, 0, len(byExt))
-	for _, v := range byExt {
-		out = append(out, v)
+	acc := r.merge(false)
+	return &TreeStats{
+		Root:       root,
+		Total:      acc.total,
+		Sizes:      acc.sizes,
+		ByChild:    acc.byChild,
+		TopFiles:   acc.top.ListDesc(),
+		RootFiles:  acc.rootFiles,
+		ChildNames: sortedChildNames(acc.byChild),
+		Seen:       res.seen,
+		Limited:    res.limited,
+		Symlinks:   res.symlinks,
+		Errors:     res.errors,
+	}, nil
+}
+
+func ScanExtStatsLimited(root string, maxFiles int) ([]ExtStatsItem, int, bool, error) {
+	stats, err := ScanExtStatsContext(context.Background(), root, Options{MaxFiles: maxFiles})
+	if err != nil {
+		return nil, 0, false, err
 	}
-	sort.Slice(out, func(i, j int) bool {
-		if out[i].Size == out[j].Size {
-			return out[i].Count > out[j].Count
-		}
-		return out[i].Size > out[j].Size
-	})
-	return out, seen, limited, nil
+	return stats.Items, stats.Seen, stats.Limited, nil
 }
 
-func ScanOverviewLimited(root string, maxFiles int, topFilesN int, topExtN int) (*OverviewStats, error) {
+// ScanExtStatsContext groups files under root by lower-cased extension, largest first.
+// The scan stops with ctx.Err() once ctx is cancelled.
+func ScanExtStatsContext(ctx context.Context, root string, opts Options) (*ExtStats, error) {
 	root = filepath.Clean(root)
-	stats := &OverviewStats{
-		Root:    root,
-		ByChild: map[string]int64{},
-	}
-	top := NewTopFiles(topFilesN)
-	extMap := map[string]ExtStatsItem{}
-	rootPrefix := root
-	if !strings.HasSuffix(rootPrefix, string(filepath.Separator)) {
-		rootPrefix += string(filepath.Separator)
+	r := newReducer(reduceConfig{root: root, mode: opts.SizeMode, byExt: true}, shardCount(opts, true))
+	res, err := walkFiles(ctx, root, opts, true, r.add)
+	if err != nil {
+		return nil, err
 	}
+	acc := r.merge(false)
+	return &ExtStats{
+		Root:     root,
+		Total:    acc.total,
+		Sizes:    acc.sizes,
+		Items:    sortedExtStats(acc.byExt),
+		Seen:     res.seen,
+		Limited:  res.limited,
+		Symlinks: res.symlinks,
+		Errors:   res.errors,
+	}, nil
+}
 
-	var mu sync.Mutex
-	seen, limited, err := walkFilesConcurrent(root, maxFiles, func(path string, size int64) {
-		ext := fastLowerExt(path)
-		rel := path
-		if strings.HasPrefix(path, rootPrefix) {
-			rel = path[len(rootPrefix):]
-		}
-		child := "(root)"
-		if first := firstPathSegment(rel); first != "" && first != rel {
-			child = first
-		}
+func ScanOverviewLimited(root string, maxFiles int, topFilesN int, topExtN int) (*OverviewStats, error) {
+	return ScanOverviewContext(context.Background(), root, topFilesN, topExtN, Options{MaxFiles: maxFiles})
+}
 
-		mu.Lock()
-		stats.Total += size
-		stats.ByChild[child] += size
-		top.Push(FileInfo{Path: path, Size: size})
-		cur := extMap[ext]
-		cur.Ext = ext
-		cur.Count++
-		cur.Size += size
-		extMap[ext] = cur
-		mu.Unlock()
-	})
+// ScanOverviewContext combines child totals, top files and extension stats in one walk.
+// Files directly under root are reported under the "(root)" child.
+// The scan stops with ctx.Err() once ctx is cancelled.
+func ScanOverviewContext(ctx context.Context, root string, topFilesN int, topExtN int, opts Options) (*OverviewStats, error) {
+	root = filepath.Clean(root)
+	cfg := reduceConfig{root: root, mode: opts.SizeMode, topBy: opts.TopBy, topN: topFilesN, byChild: true, byExt: true, rootName: "(root)"}
+	r := newReducer(cfg, shardCount(opts, true))
+	res, err := walkFiles(ctx, root, opts, true, r.add)
 	if err != nil {
 		return nil, err
 	}
-
-	stats.Seen = seen
-	stats.Limited = limited
-	stats.TopFiles = top.ListDesc()
-	stats.ExtStats = make([]ExtStatsItem, 0, len(extMap))
-	for _, v := range extMap {
-		stats.ExtStats = append(stats.ExtStats, v)
+	acc := r.merge(false)
+	stats := &OverviewStats{
+		Root:     root,
+		Total:    acc.total,
+		Sizes:    acc.sizes,
+		Seen:     res.seen,
+		Limited:  res.limited,
+		Symlinks: res.symlinks,
+		Errors:   res.errors,
+		ByChild:  acc.byChild,
+		TopFiles: acc.top.ListDesc(),
+		ExtStats: sortedExtStats(acc.byExt),
 	}
-	sort.Slice(stats.ExtStats, func(i, j int) bool {
-		if stats.ExtStats[i].Size == stats.ExtStats[j].Size {
-			return stats.ExtStats[i].Count > stats.ExtStats[j].Count
-		}
-		return stats.ExtStats[i].Size > stats.ExtStats[j].Size
-	})
 	if topExtN > 0 && len(stats.ExtStats) > topExtN {
 		stats.ExtStats = stats.ExtStats[:topExtN]
 	}
 	return stats, nil
 }
+
+func sortedExtStats(byExt map[string]ExtStatsItem) []ExtStatsItem {
+	out := make([]ExtStatsItem, 0, len(byExt))
+	for _, v := range byExt {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Size == out[j].Size {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Size > out[j].Size
+	})
+	return out
+}
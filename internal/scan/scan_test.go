@@ -1,9 +1,17 @@
 package scan
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
+
+	"icicle/internal/skip"
 )
 
 func TestScanTree(t *testing.T) {
@@ -34,6 +42,561 @@ func TestScanTree(t *testing.T) {
 	}
 }
 
+func TestScanContextCancelled(t *testing.T) {
+	root := t.TempDir()
+	mustWriteSized(t, filepath.Join(root, "a", "x.bin"), 10)
+	mustWriteSized(t, filepath.Join(root, "b", "y.bin"), 20)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ScanTopFilesContext(ctx, root, 5, Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ScanTopFilesContext err=%v want context.Canceled", err)
+	}
+	if _, err := ScanTreeContext(ctx, root, 5, Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ScanTreeContext err=%v want context.Canceled", err)
+	}
+	if _, err := WalkAllContext(ctx, root, Options{}, func(string, int64) {}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("WalkAllContext err=%v want context.Canceled", err)
+	}
+
+	stats, err := ScanExtStatsContext(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatalf("ScanExtStatsContext error: %v", err)
+	}
+	if stats.Seen != 2 || stats.Total != 30 || stats.Limited {
+		t.Fatalf("ext stats mismatch: seen=%d total=%d limited=%v", stats.Seen, stats.Total, stats.Limited)
+	}
+}
+
+func TestScanOptions(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 6; i++ {
+		mustWriteSized(t, filepath.Join(root, "d", string(rune('a'+i))+".bin"), 10)
+	}
+
+	stats, err := ScanTopFilesContext(context.Background(), root, 3, Options{Workers: 1, MaxFiles: 4})
+	if err != nil {
+		t.Fatalf("ScanTopFilesContext error: %v", err)
+	}
+	if !stats.Limited || stats.Seen != 4 {
+		t.Fatalf("limit mismatch: seen=%d limited=%v", stats.Seen, stats.Limited)
+	}
+
+	skipped, err := ScanTreeContext(context.Background(), root, 3, Options{Skip: &skip.Policy{Patterns: []string{"d"}}})
+	if err != nil {
+		t.Fatalf("ScanTreeContext error: %v", err)
+	}
+	if skipped.Total != 0 {
+		t.Fatalf("skip dir total mismatch: got %d want 0", skipped.Total)
+	}
+}
+
+func TestFilterPatterns(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"node_modules", "/src/app/Node_Modules/x.js", true},
+		{"*.log", "/var/log/app.log", true},
+		{"*.log", "/var/log/app.log.1", false},
+		{"build*", "/src/build-out/a.o", true},
+		{"src/*/testdata", "/home/u/src/pkg/testdata/f.txt", true},
+		{"src/*/testdata", "/home/u/src/a/b/testdata/f.txt", false},
+		{"src/**/testdata", "/home/u/src/a/b/testdata/f.txt", true},
+		{"/home/*/Downloads", "/home/u/Downloads/a.zip", true},
+		{"/home/*/Downloads", "/mnt/home/u/Downloads/a.zip", false},
+		{"photo-[0-9].jpg", "/p/photo-7.jpg", true},
+		{"photo-[!0-9].jpg", "/p/photo-7.jpg", false},
+		{`re:\.(tmp|bak)$`, "/p/a.bak", true},
+		{`re:\.(tmp|bak)$`, "/p/a.bak/x", false},
+	}
+	for _, tc := range cases {
+		f, err := NewFilter(FilterSpec{Include: []string{tc.pattern}})
+		if err != nil {
+			t.Fatalf("NewFilter(%q): %v", tc.pattern, err)
+		}
+		if got := f.MatchPath(tc.path); got != tc.want {
+			t.Errorf("%q on %q = %v want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+	if _, err := NewFilter(FilterSpec{Exclude: []string{"re:("}}); err == nil {
+		t.Fatal("bad regex should fail")
+	}
+	if f, _ := NewFilter(FilterSpec{Include: []string{" "}}); f != nil {
+		t.Fatal("empty spec should yield a nil filter")
+	}
+}
+
+func TestFilterPredicates(t *testing.T) {
+	now := time.Now()
+	f, err := NewFilter(FilterSpec{
+		IncludeExt:    []string{"MP4,mkv"},
+		ExcludeExt:    []string{".mkv"},
+		Exclude:       []string{"cache"},
+		MinSize:       10,
+		MaxSize:       100,
+		ModifiedAfter: now.Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path  string
+		size  int64
+		mtime time.Time
+		want  bool
+	}{
+		{"/v/a.mp4", 50, now, true},
+		{"/v/a.mkv", 50, now, false},
+		{"/v/a.avi", 50, now, false},
+		{"/v/cache/a.mp4", 50, now, false},
+		{"/v/a.mp4", 5, now, false},
+		{"/v/a.mp4", 500, now, false},
+		{"/v/a.mp4", 50, now.Add(-2 * time.Hour), false},
+	}
+	for _, tc := range cases {
+		if got := f.Match(tc.path, tc.size, tc.mtime); got != tc.want {
+			t.Errorf("Match(%q, %d) = %v want %v", tc.path, tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestScanFilter(t *testing.T) {
+	cfg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", cfg)
+	t.Setenv("APPDATA", cfg)
+	root := t.TempDir()
+	mustWriteSized(t, filepath.Join(root, "keep", "a.bin"), 10)
+	mustWriteSized(t, filepath.Join(root, "keep", "b.txt"), 20)
+	mustWriteSized(t, filepath.Join(root, "drop", "c.bin"), 40)
+	filter, err := NewFilter(FilterSpec{IncludeExt: []string{".bin"}, Exclude: []string{"drop/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := OpenIndex(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second pass replays the index and must filter the same way.
+	for pass := 0; pass < 2; pass++ {
+		stats, err := ScanTreeContext(context.Background(), root, 5, Options{Filter: filter, Index: idx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Total != 10 || stats.Seen != 1 || stats.ByChild["drop"] != 0 {
+			t.Fatalf("pass %d: total=%d seen=%d by child=%v", pass, stats.Total, stats.Seen, stats.ByChild)
+		}
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	sizes := map[string]int64{"4096": 4096, "500MB": 500 << 20, "1.5g": 3 << 29, "2 KiB": 2048, "1t": 1 << 40}
+	for in, want := range sizes {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v want %d", in, got, err, want)
+		}
+	}
+	day := 24 * time.Hour
+	ages := map[string]time.Duration{"90d": 90 * day, "2w": 14 * day, "6mo": 180 * day, "1y": 365 * day, "36h": 36 * time.Hour, "0.5d": 12 * time.Hour}
+	for in, want := range ages {
+		if got, err := ParseAge(in); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v want %v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "MB", "10xb", "-5MB"} {
+		if _, err := ParseSize(bad); err == nil {
+			t.Errorf("ParseSize(%q) should fail", bad)
+		}
+	}
+	for _, bad := range []string{"", "d", "5 parsecs", "-3d"} {
+		if _, err := ParseAge(bad); err == nil {
+			t.Errorf("ParseAge(%q) should fail", bad)
+		}
+	}
+}
+
+func TestTopOrder(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	files := []struct {
+		name string
+		size int
+		age  time.Duration
+	}{
+		{"big-new.bin", 100, 0},
+		{"mid-old.bin", 40, 400 * 24 * time.Hour},
+		{"small-ancient.bin", 1, 2000 * 24 * time.Hour},
+	}
+	for _, f := range files {
+		p := filepath.Join(root, f.name)
+		mustWriteSized(t, p, f.size)
+		if err := os.Chtimes(p, now, now.Add(-f.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[TopOrder][]string{
+		TopBySize:        {"big-new.bin", "mid-old.bin", "small-ancient.bin"},
+		TopByModTime:     {"small-ancient.bin", "mid-old.bin", "big-new.bin"},
+		TopByAgeWeighted: {"mid-old.bin", "small-ancient.bin", "big-new.bin"},
+	}
+	for order, names := range want {
+		stats, err := ScanTopFilesContext(context.Background(), root, 3, Options{TopBy: order})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, f := range stats.TopFiles {
+			if filepath.Base(f.Path) != names[i] {
+				t.Fatalf("%v: position %d is %s, want %s", order, i, filepath.Base(f.Path), names[i])
+			}
+		}
+		if order == TopByModTime && now.Sub(stats.TopFiles[0].ModTime) < 1999*24*time.Hour {
+			t.Fatalf("mod time not carried: %v", stats.TopFiles[0].ModTime)
+		}
+	}
+	if _, err := ParseTopOrder("age-weighted"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseTopOrder("name"); err == nil {
+		t.Fatal("unknown sort should fail")
+	}
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	mustWriteSized(t, filepath.Join(root, "real", "a.bin"), 10)
+	mustWriteSized(t, filepath.Join(outside, "b.bin"), 20)
+	if err := os.Symlink(outside, filepath.Join(root, "ext")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "loop")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	plain, err := ScanTreeContext(context.Background(), root, 5, Options{})
+	if err != nil {
+		t.Fatalf("ScanTreeContext error: %v", err)
+	}
+	if plain.Total != 10 {
+		t.Fatalf("plain total mismatch: got %d want 10", plain.Total)
+	}
+	followed, err := ScanTreeContext(context.Background(), root, 5, Options{FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("ScanTreeContext follow error: %v", err)
+	}
+	if followed.Total != 30 {
+		t.Fatalf("followed total mismatch: got %d want 30", followed.Total)
+	}
+	if len(plain.Symlinks) != 2 || plain.Symlinks[0].Followed || plain.Symlinks[0].Reason != "not followed" {
+		t.Fatalf("plain symlinks mismatch: %+v", plain.Symlinks)
+	}
+	if len(followed.Symlinks) != 2 || !followed.Symlinks[0].Followed || followed.Symlinks[1].Reason != "target inside root" {
+		t.Fatalf("followed symlinks mismatch: %+v", followed.Symlinks)
+	}
+	if followed.Symlinks[0].Target != outside {
+		t.Fatalf("symlink target mismatch: %+v", followed.Symlinks[0])
+	}
+}
+
+func TestFollowSymlinksCycle(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	mustWriteSized(t, filepath.Join(outside, "b.bin"), 20)
+	if err := os.Symlink(outside, filepath.Join(root, "ext")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(outside, "again")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	stats, err := ScanTopFilesContext(context.Background(), root, 5, Options{FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("ScanTopFilesContext error: %v", err)
+	}
+	if stats.Total != 20 || stats.Seen != 1 {
+		t.Fatalf("cycle counted twice: total=%d seen=%d", stats.Total, stats.Seen)
+	}
+	reasons := map[string]string{}
+	for _, l := range stats.Symlinks {
+		reasons[filepath.Base(l.Path)] = l.Reason
+	}
+	if reasons["again"] != "already walked" || reasons["dangling"] != "dangling" || reasons["ext"] != "" {
+		t.Fatalf("symlink reasons mismatch: %v", reasons)
+	}
+}
+
+func TestScanDirTree(t *testing.T) {
+	root := t.TempDir()
+	mustWriteSized(t, filepath.Join(root, "a", "x", "big.bin"), 50)
+	mustWriteSized(t, filepath.Join(root, "a", "small.txt"), 5)
+	mustWriteSized(t, filepath.Join(root, "b", "one.bin"), 20)
+	mustWriteSized(t, filepath.Join(root, "root.txt"), 1)
+	if err := os.MkdirAll(filepath.Join(root, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := ScanDirTreeContext(context.Background(), root, 2, Options{})
+	if err != nil {
+		t.Fatalf("ScanDirTreeContext error: %v", err)
+	}
+	if tree.Root.Size != 76 || tree.Root.Files != 4 || tree.Root.Dirs != 4 || tree.Root.Own != 1 {
+		t.Fatalf("root mismatch: size=%d files=%d dirs=%d own=%d", tree.Root.Size, tree.Root.Files, tree.Root.Dirs, tree.Root.Own)
+	}
+	if c := tree.Root.LargestChild(); c == nil || c.Name != "a" {
+		t.Fatalf("largest child mismatch: %+v", c)
+	}
+	a := tree.Find("a")
+	if a == nil || a.Size != 55 || a.Files != 2 || a.Dirs != 1 {
+		t.Fatalf("node a mismatch: %+v", a)
+	}
+	if a.Largest.Size != 50 {
+		t.Fatalf("largest file mismatch: %+v", a.Largest)
+	}
+	x := tree.Find(filepath.Join(root, "a", "x"))
+	if x == nil || x.Path() != filepath.Join(root, "a", "x") {
+		t.Fatalf("absolute lookup failed: %+v", x)
+	}
+	if e := tree.Find("empty"); e == nil || e.Size != 0 {
+		t.Fatalf("empty dir missing: %+v", e)
+	}
+	if len(tree.TopFiles) != 2 || tree.TopFiles[0].Size != 50 {
+		t.Fatalf("top files mismatch: %+v", tree.TopFiles)
+	}
+}
+
+func TestIndexReplaysUnchangedDirs(t *testing.T) {
+	cfg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", cfg)
+	t.Setenv("APPDATA", cfg)
+	root := t.TempDir()
+	mustWriteSized(t, filepath.Join(root, "a", "one.bin"), 10)
+	mustWriteSized(t, filepath.Join(root, "b", "two.bin"), 20)
+	mustWriteSized(t, filepath.Join(root, "c", "three.bin"), 30)
+
+	idx, err := OpenIndex(root)
+	if err != nil {
+		t.Fatalf("OpenIndex error: %v", err)
+	}
+	first, err := ScanTreeContext(context.Background(), root, 5, Options{Index: idx})
+	if err != nil {
+		t.Fatalf("ScanTreeContext error: %v", err)
+	}
+	if first.Total != 60 {
+		t.Fatalf("first total mismatch: got %d want 60", first.Total)
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	// Rewriting a file in place leaves the directory mtime alone, so the indexed size is reused.
+	aDir := filepath.Join(root, "a")
+	aInfo, err := os.Stat(aDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWriteSized(t, filepath.Join(aDir, "one.bin"), 15)
+	if err := os.Chtimes(aDir, aInfo.ModTime(), aInfo.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	mustWriteSized(t, filepath.Join(root, "b", "new.bin"), 5)
+	if err := os.RemoveAll(filepath.Join(root, "c")); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err = OpenIndex(root)
+	if err != nil {
+		t.Fatalf("OpenIndex reload error: %v", err)
+	}
+	if idx.Len() != 4 {
+		t.Fatalf("indexed dirs mismatch: got %d want 4", idx.Len())
+	}
+	second, err := ScanTreeContext(context.Background(), root, 5, Options{Index: idx})
+	if err != nil {
+		t.Fatalf("ScanTreeContext indexed error: %v", err)
+	}
+	if second.Total != 35 {
+		t.Fatalf("indexed total mismatch: got %d want 35", second.Total)
+	}
+	if idx.Len() != 3 {
+		t.Fatalf("removed dir still indexed: got %d dirs want 3", idx.Len())
+	}
+	var fromIndex int64
+	idx.Walk(SizeApparent, func(_ string, size int64) { fromIndex += size })
+	if fromIndex != 35 {
+		t.Fatalf("Walk total mismatch: got %d want 35", fromIndex)
+	}
+}
+
+func TestHardLinksCountedOnce(t *testing.T) {
+	root := t.TempDir()
+	mustWriteSized(t, filepath.Join(root, "a", "data.bin"), 100)
+	if err := os.MkdirAll(filepath.Join(root, "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(root, "a", "data.bin"), filepath.Join(root, "b", "data.bin")); err != nil {
+		t.Skipf("hard links unavailable: %v", err)
+	}
+	stats, err := ScanTopFilesContext(context.Background(), root, 5, Options{})
+	if err != nil {
+		t.Fatalf("ScanTopFilesContext error: %v", err)
+	}
+	if runtime.GOOS == "windows" {
+		// Inode identity is not exposed there, so both names are counted.
+		return
+	}
+	if stats.Seen != 1 || stats.Total != 100 || len(stats.TopFiles) != 1 {
+		t.Fatalf("hard link counted twice: seen=%d total=%d top=%d", stats.Seen, stats.Total, len(stats.TopFiles))
+	}
+	if stats.Sizes.Apparent != 100 || stats.Sizes.Allocated <= 0 {
+		t.Fatalf("size totals mismatch: %+v", stats.Sizes)
+	}
+	alloc, err := ScanTopFilesContext(context.Background(), root, 5, Options{SizeMode: SizeAllocated})
+	if err != nil {
+		t.Fatalf("ScanTopFilesContext allocated error: %v", err)
+	}
+	if alloc.Total != stats.Sizes.Allocated || alloc.TopFiles[0].Size != alloc.Total {
+		t.Fatalf("allocated mode mismatch: total=%d top=%+v want %d", alloc.Total, alloc.TopFiles, stats.Sizes.Allocated)
+	}
+	all, err := ScanTopFilesContext(context.Background(), root, 5, Options{AllLinks: true})
+	if err != nil {
+		t.Fatalf("ScanTopFilesContext AllLinks error: %v", err)
+	}
+	if all.Seen != 2 || all.Total != 200 {
+		t.Fatalf("AllLinks should report both names: seen=%d total=%d", all.Seen, all.Total)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	root := t.TempDir()
+	mustWriteSized(t, filepath.Join(root, "ok", "a.bin"), 10)
+	mustWriteSized(t, filepath.Join(root, "locked", "b.bin"), 20)
+	if err := os.Chmod(filepath.Join(root, "locked"), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(filepath.Join(root, "locked"), 0o755)
+	if _, err := os.ReadDir(filepath.Join(root, "locked")); err == nil {
+		t.Skip("permissions are not enforced for this user")
+	}
+
+	stats, err := ScanTopFilesContext(context.Background(), root, 5, Options{Strict: true})
+	if err != nil {
+		t.Fatalf("denied dir aborted the scan: %v", err)
+	}
+	if stats.Total != 10 || stats.Errors.Denied.Dirs != 1 || len(stats.Errors.Items) != 1 {
+		t.Fatalf("errors mismatch: total=%d errors=%+v", stats.Total, stats.Errors)
+	}
+	if got := stats.Errors.Summary(); got != "1 dirs unreadable" {
+		t.Fatalf("summary mismatch: %q", got)
+	}
+}
+
+func TestScanTreeErrors(t *testing.T) {
+	cfg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", cfg)
+	t.Setenv("APPDATA", cfg)
+	root := t.TempDir()
+	mustWriteSized(t, filepath.Join(root, "ok", "a.bin"), 10)
+	mustWriteSized(t, filepath.Join(root, "gone", "b.bin"), 20)
+	mustWriteSized(t, filepath.Join(root, "locked", "c.bin"), 30)
+
+	// Index the tree, then remove a folder and restore the root mtime: the root listing is
+	// replayed from the index, so the walk tries to enter a folder that is no longer there.
+	idx, err := OpenIndex(root)
+	if err != nil {
+		t.Fatalf("OpenIndex error: %v", err)
+	}
+	if _, err := ScanTreeContext(context.Background(), root, 5, Options{Index: idx}); err != nil {
+		t.Fatalf("ScanTreeContext error: %v", err)
+	}
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(root, "gone")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(root, rootInfo.ModTime(), rootInfo.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0o755)
+	_, lockErr := os.ReadDir(locked)
+
+	stats, err := ScanTreeContext(context.Background(), root, 5, Options{Index: idx})
+	if err != nil {
+		t.Fatalf("errors aborted the scan: %v", err)
+	}
+	if stats.Errors.Vanished.Dirs != 1 {
+		t.Fatalf("vanished dir not reported: %+v", stats.Errors)
+	}
+	if lockErr == nil {
+		// Permissions are not enforced for this user; only the vanished folder applies.
+		if stats.Total != 40 || stats.Errors.Total() != 1 {
+			t.Fatalf("errors mismatch: total=%d errors=%+v", stats.Total, stats.Errors)
+		}
+		return
+	}
+	if stats.Total != 10 || stats.Errors.Denied.Dirs != 1 || stats.Errors.Total() != 2 {
+		t.Fatalf("errors mismatch: total=%d errors=%+v", stats.Total, stats.Errors)
+	}
+	if got := stats.Errors.Summary(); got != "1 dirs unreadable, 1 dirs vanished" {
+		t.Fatalf("summary mismatch: %q", got)
+	}
+}
+
+func TestScanMissingRoot(t *testing.T) {
+	_, err := ScanTopFilesContext(context.Background(), filepath.Join(t.TempDir(), "gone"), 5, Options{})
+	var pe *PathError
+	if !errors.As(err, &pe) || pe.Kind != ErrVanished || !pe.Dir {
+		t.Fatalf("expected vanished root error, got %v", err)
+	}
+
+	var e ScanErrors
+	e.add(PathError{Path: "a", Kind: ErrDenied, Dir: true, Err: os.ErrPermission})
+	e.add(PathError{Path: "b", Kind: ErrVanished, Err: os.ErrNotExist})
+	e.add(PathError{Path: "c", Kind: ErrIO, Err: errors.New("bad sector")})
+	if e.Total() != 3 || e.Summary() != "1 dirs unreadable, 1 files vanished, 1 files with I/O errors" {
+		t.Fatalf("summary mismatch: %d %q", e.Total(), e.Summary())
+	}
+}
+
+func TestStream(t *testing.T) {
+	root := t.TempDir()
+	mustWriteSized(t, filepath.Join(root, "Videos", "a.mp4"), 10)
+	mustWriteSized(t, filepath.Join(root, "Videos", "b.mkv"), 30)
+	mustWriteSized(t, filepath.Join(root, "note.txt"), 5)
+
+	var final Progress
+	updates := 0
+	for p := range Stream(context.Background(), root, Options{}, StreamOptions{Interval: time.Millisecond, TopFiles: 2}) {
+		updates++
+		final = p
+	}
+	if !final.Done || final.Err != nil {
+		t.Fatalf("final progress mismatch: done=%v err=%v", final.Done, final.Err)
+	}
+	if final.Seen != 3 || final.Total != 45 {
+		t.Fatalf("totals mismatch: seen=%d total=%d", final.Seen, final.Total)
+	}
+	tree := final.TreeStats()
+	if tree.ByChild["Videos"] != 40 || tree.RootFiles != 5 || len(tree.ChildNames) != 1 {
+		t.Fatalf("tree mismatch: %+v", tree)
+	}
+	if len(final.TopFiles) != 2 || final.TopFiles[0].Size != 30 {
+		t.Fatalf("top files mismatch: %+v", final.TopFiles)
+	}
+	if len(final.ExtStats) != 3 {
+		t.Fatalf("ext stats mismatch: %+v", final.ExtStats)
+	}
+}
+
 func mustWriteSized(t *testing.T, path string, size int) {
 	t.Helper()
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
@@ -45,6 +608,35 @@ func mustWriteSized(t *testing.T, path string, size int) {
 	}
 }
 
+func TestWalkAllFileRoot(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.bin")
+	mustWriteSized(t, file, 42)
+
+	var got []string
+	var size int64
+	if err := WalkAll(file, func(p string, s int64) {
+		got = append(got, p)
+		size = s
+	}); err != nil {
+		t.Fatalf("WalkAll error: %v", err)
+	}
+	if len(got) != 1 || got[0] != file || size != 42 {
+		t.Fatalf("file root mismatch: %v size=%d", got, size)
+	}
+	n, err := WalkAllLimit(file, 5, func(string, int64) {})
+	if err != nil || n != 1 {
+		t.Fatalf("WalkAllLimit = %d, %v", n, err)
+	}
+	f, err := NewFilter(FilterSpec{MinSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = WalkAllContext(context.Background(), file, Options{Filter: f}, func(string, int64) {})
+	if err != nil || n != 0 {
+		t.Fatalf("filtered file root = %d, %v", n, err)
+	}
+}
+
 func TestFastLowerExt(t *testing.T) {
 	cases := []struct {
 		in   string
@@ -82,3 +674,107 @@ func TestFirstPathSegment(t *testing.T) {
 		}
 	}
 }
+
+func TestReducerMergesShards(t *testing.T) {
+	root := filepath.Join("data")
+	r := newReducer(reduceConfig{root: root, topN: 2, byChild: true, byExt: true}, 3)
+	r.add(0, filepath.Join(root, "a", "x.mp4"), fileStat{apparent: 10, allocated: 4096})
+	r.add(1, filepath.Join(root, "a", "y.mp4"), fileStat{apparent: 30, allocated: 4096})
+	r.add(2, filepath.Join(root, "b", "z.zip"), fileStat{apparent: 20, allocated: 4096})
+	r.add(2, filepath.Join(root, "top.txt"), fileStat{apparent: 5, allocated: 4096})
+
+	acc := r.merge(false)
+	if acc.seen != 4 || acc.total != 65 || acc.rootFiles != 5 {
+		t.Fatalf("merge totals mismatch: seen=%d total=%d root=%d", acc.seen, acc.total, acc.rootFiles)
+	}
+	if acc.sizes.Apparent != 65 || acc.sizes.Allocated != 4*4096 {
+		t.Fatalf("merge size totals mismatch: %+v", acc.sizes)
+	}
+	if acc.byChild["a"] != 40 || acc.byChild["b"] != 20 {
+		t.Fatalf("merge children mismatch: %v", acc.byChild)
+	}
+	if acc.byExt[".mp4"].Count != 2 || acc.byExt[".mp4"].Size != 40 {
+		t.Fatalf("merge ext mismatch: %+v", acc.byExt[".mp4"])
+	}
+	top := acc.top.ListDesc()
+	if len(top) != 2 || top[0].Size != 30 || top[1].Size != 20 {
+		t.Fatalf("merge top mismatch: %+v", top)
+	}
+}
+
+// The reducer benchmarks feed a synthetic 500k-file tree through 32 producer goroutines,
+// the same fan-in the parallel walker produces, without touching the disk.
+const (
+	benchTreeFiles   = 500000
+	benchTreeWorkers = 32
+)
+
+var benchTree struct {
+	once  sync.Once
+	root  string
+	paths []string
+	sizes []int64
+}
+
+func syntheticTree() (string, []string, []int64) {
+	benchTree.once.Do(func() {
+		exts := []string{".mp4", ".jpg", ".zip", ".txt", ".go", ".pdf", ".iso", ".log"}
+		root := filepath.Join("bench", "root")
+		benchTree.root = root
+		benchTree.paths = make([]string, 0, benchTreeFiles)
+		benchTree.sizes = make([]int64, 0, benchTreeFiles)
+		for i := 0; i < benchTreeFiles; i++ {
+			dir := filepath.Join(root, "d"+strconv.Itoa(i%200), "s"+strconv.Itoa((i/200)%50))
+			benchTree.paths = append(benchTree.paths, filepath.Join(dir, "f"+strconv.Itoa(i)+exts[i%len(exts)]))
+			benchTree.sizes = append(benchTree.sizes, int64((uint32(i)*2654435761)>>6))
+		}
+	})
+	return benchTree.root, benchTree.paths, benchTree.sizes
+}
+
+func feedTree(paths []string, sizes []int64, add shardFunc) {
+	var wg sync.WaitGroup
+	chunk := (len(paths) + benchTreeWorkers - 1) / benchTreeWorkers
+	for w := 0; w < benchTreeWorkers; w++ {
+		lo := w * chunk
+		hi := lo + chunk
+		if hi > len(paths) {
+			hi = len(paths)
+		}
+		wg.Add(1)
+		go func(shard, lo, hi int) {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				add(shard, paths[i], fileStat{apparent: sizes[i], allocated: sizes[i]})
+			}
+		}(w+1, lo, hi)
+	}
+	wg.Wait()
+}
+
+// BenchmarkReduceMutex is the pre-sharding consumer: the same aggregates in one accumulator
+// behind one shared lock, so the only difference to BenchmarkReduceSharded is the locking.
+func BenchmarkReduceMutex(b *testing.B) {
+	root, paths, sizes := syntheticTree()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		r := newReducer(reduceConfig{root: root, topN: 80, byChild: true, byExt: true}, 1)
+		feedTree(paths, sizes, func(_ int, path string, fs fileStat) {
+			r.addLocked(0, path, fs)
+		})
+		r.merge(false)
+	}
+}
+
+// BenchmarkReduceSharded is the reducer used by the Scan* functions: one accumulator per shard, merged once.
+func BenchmarkReduceSharded(b *testing.B) {
+	root, paths, sizes := syntheticTree()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		r := newReducer(reduceConfig{root: root, topN: 80, byChild: true, byExt: true}, benchTreeWorkers+1)
+		feedTree(paths, sizes, r.add)
+		r.merge(false)
+	}
+}
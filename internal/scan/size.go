@@ -0,0 +1,67 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SizeMode selects which size of a file is displayed, sorted and summed.
+type SizeMode int
+
+const (
+	// SizeApparent is the byte length reported by stat (st_size).
+	SizeApparent SizeMode = iota
+	// SizeAllocated is the space taken on disk (st_blocks * 512 on Unix). It is smaller than the
+	// apparent size for sparse files and includes block rounding. Other platforms report the apparent size.
+	SizeAllocated
+)
+
+// ParseSizeMode accepts "apparent" or "allocated".
+func ParseSizeMode(s string) (SizeMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "apparent":
+		return SizeApparent, nil
+	case "allocated", "disk":
+		return SizeAllocated, nil
+	}
+	return SizeApparent, fmt.Errorf("unknown size mode %q (use apparent or allocated)", s)
+}
+
+func (m SizeMode) String() string {
+	if m == SizeAllocated {
+		return "allocated"
+	}
+	return "apparent"
+}
+
+// SizeTotals sums both size measures of a scan regardless of the selected SizeMode.
+// Files with several hard links are counted once per scan.
+type SizeTotals struct {
+	Apparent  int64
+	Allocated int64
+}
+
+// fileStat is what the walker hands to reducers for one file. Times are Unix nanoseconds.
+type fileStat struct {
+	apparent  int64
+	allocated int64
+	mtime     int64
+	atime     int64
+}
+
+func (s fileStat) pick(m SizeMode) int64 {
+	if m == SizeAllocated {
+		return s.allocated
+	}
+	return s.apparent
+}
+
+func (s fileStat) info(path string, mode SizeMode) FileInfo {
+	return FileInfo{Path: path, Size: s.pick(mode), ModTime: time.Unix(0, s.mtime), AccessTime: time.Unix(0, s.atime)}
+}
+
+type inodeKey struct {
+	dev uint64
+	ino uint64
+}
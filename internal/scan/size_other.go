@@ -0,0 +1,16 @@
+//go:build !unix
+
+package scan
+
+import "os"
+
+// statFile falls back to the apparent size where block counts and inode numbers are not exposed.
+func statFile(info os.FileInfo) (fileStat, inodeKey, bool) {
+	mtime := info.ModTime().UnixNano()
+	return fileStat{apparent: info.Size(), allocated: info.Size(), mtime: mtime, atime: accessTime(info, mtime)}, inodeKey{}, false
+}
+
+// dirKey is unavailable here; linked directories are deduplicated by resolved path instead.
+func dirKey(os.FileInfo) (inodeKey, bool) {
+	return inodeKey{}, false
+}
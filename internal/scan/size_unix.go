@@ -0,0 +1,28 @@
+//go:build unix
+
+package scan
+
+import (
+	"os"
+	"syscall"
+)
+
+// statFile reads both size measures, the file times and, for files with several hard links, the inode identity.
+func statFile(info os.FileInfo) (fileStat, inodeKey, bool) {
+	mtime := info.ModTime().UnixNano()
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileStat{apparent: info.Size(), allocated: info.Size(), mtime: mtime, atime: mtime}, inodeKey{}, false
+	}
+	size := fileStat{apparent: info.Size(), allocated: int64(st.Blocks) * 512, mtime: mtime, atime: accessTime(st)}
+	return size, inodeKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink) > 1
+}
+
+// dirKey identifies a directory by device and inode for symlink cycle detection.
+func dirKey(info os.FileInfo) (inodeKey, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return inodeKey{}, false
+	}
+	return inodeKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Progress is a partial aggregate emitted while a streaming scan runs.
+// The last value on the channel has Done set and carries the final totals and error.
+type Progress struct {
+	Root      string
+	Seen      int
+	Total     int64
+	Sizes     SizeTotals
+	Elapsed   time.Duration
+	TopFiles  []FileInfo
+	ByChild   map[string]int64
+	RootFiles int64
+	ExtStats  []ExtStatsItem
+	Limited   bool
+	// Symlinks and Errors are only filled in the final update.
+	Symlinks []Symlink
+	Errors   ScanErrors
+	Done     bool
+	Err      error
+}
+
+type StreamOptions struct {
+	// Interval between partial updates; <= 0 uses 250ms.
+	Interval time.Duration
+	// TopFiles is the size of the running top-N list.
+	TopFiles int
+	// TopExt caps ExtStats in each update; <= 0 keeps every extension.
+	TopExt int
+}
+
+// Stream scans root in the background and sends a Progress snapshot every Interval.
+// Partial updates are dropped while the receiver is busy, so a slow consumer never stalls the walk.
+// A final Progress with Done set is always sent before the channel is closed; callers must drain it.
+func Stream(ctx context.Context, root string, opts Options, so StreamOptions) <-chan Progress {
+	root = filepath.Clean(root)
+	if so.Interval <= 0 {
+		so.Interval = 250 * time.Millisecond
+	}
+	out := make(chan Progress, 1)
+	r := newReducer(reduceConfig{root: root, mode: opts.SizeMode, topBy: opts.TopBy, topN: so.TopFiles, byChild: true, byExt: true}, shardCount(opts, true))
+	snapshot := func() Progress {
+		acc := r.merge(true)
+		p := Progress{
+			Root:      root,
+			Seen:      acc.seen,
+			Total:     acc.total,
+			Sizes:     acc.sizes,
+			TopFiles:  acc.top.ListDesc(),
+			ByChild:   acc.byChild,
+			RootFiles: acc.rootFiles,
+			ExtStats:  sortedExtStats(acc.byExt),
+		}
+		if so.TopExt > 0 && len(p.ExtStats) > so.TopExt {
+			p.ExtStats = p.ExtStats[:so.TopExt]
+		}
+		return p
+	}
+	started := time.Now()
+
+	go func() {
+		defer close(out)
+		walkDone := make(chan struct{})
+		var (
+			res walkResult
+			err error
+		)
+		go func() {
+			res, err = walkFiles(ctx, root, opts, true, r.addLocked)
+			close(walkDone)
+		}()
+
+		ticker := time.NewTicker(so.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-walkDone:
+				p := snapshot()
+				p.Seen = res.seen
+				p.Limited = res.limited
+				p.Symlinks = res.symlinks
+				p.Errors = res.errors
+				p.Elapsed = time.Since(started)
+				p.Done = true
+				p.Err = err
+				out <- p
+				return
+			case <-ticker.C:
+				p := snapshot()
+				p.Elapsed = time.Since(started)
+				select {
+				case out <- p:
+				default:
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// HeavyStats converts a progress snapshot into the shape returned by ScanTopFiles.
+func (p Progress) HeavyStats() *HeavyStats {
+	return &HeavyStats{
+		Root:     p.Root,
+		Total:    p.Total,
+		Sizes:    p.Sizes,
+		TopFiles: p.TopFiles,
+		Seen:     p.Seen,
+		Limited:  p.Limited,
+		Symlinks: p.Symlinks,
+		Errors:   p.Errors,
+	}
+}
+
+// TreeStats converts a progress snapshot into the shape returned by ScanTree.
+func (p Progress) TreeStats() *TreeStats {
+	stats := &TreeStats{
+		Root:      p.Root,
+		Total:     p.Total,
+		Sizes:     p.Sizes,
+		ByChild:   p.ByChild,
+		TopFiles:  p.TopFiles,
+		RootFiles: p.RootFiles,
+		Seen:      p.Seen,
+		Limited:   p.Limited,
+		Symlinks:  p.Symlinks,
+		Errors:    p.Errors,
+	}
+	stats.ChildNames = sortedChildNames(p.ByChild)
+	return stats
+}
+
+func sortedChildNames(byChild map[string]int64) []string {
+	names := make([]string, 0, len(byChild))
+	for name := range byChild {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return byChild[names[i]] > byChild[names[j]]
+	})
+	return names
+}
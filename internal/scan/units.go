@@ -0,0 +1,75 @@
+package scan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseSize reads sizes like "500MB", "1.5g" or "4096". Units are binary (1 KB = 1024 bytes),
+// matching ui.HumanBytes.
+func ParseSize(s string) (int64, error) {
+	num, unit := splitUnit(s)
+	var mult float64
+	switch strings.ToLower(unit) {
+	case "", "b":
+		mult = 1
+	case "k", "kb", "kib":
+		mult = 1 << 10
+	case "m", "mb", "mib":
+		mult = 1 << 20
+	case "g", "gb", "gib":
+		mult = 1 << 30
+	case "t", "tb", "tib":
+		mult = 1 << 40
+	case "p", "pb", "pib":
+		mult = 1 << 50
+	default:
+		return 0, fmt.Errorf("bad size %q: unknown unit %q", s, unit)
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return int64(n * mult), nil
+}
+
+// ParseAge reads ages like "90d", "2w", "6mo", "1y" or any time.ParseDuration value such as "36h".
+// A month is 30 days and a year 365 days.
+func ParseAge(s string) (time.Duration, error) {
+	num, unit := splitUnit(s)
+	day := 24 * time.Hour
+	var mult time.Duration
+	switch strings.ToLower(unit) {
+	case "d":
+		mult = day
+	case "w":
+		mult = 7 * day
+	case "mo":
+		mult = 30 * day
+	case "y":
+		mult = 365 * day
+	default:
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("bad age %q (use e.g. 12h, 90d, 2w, 6mo, 1y)", s)
+		}
+		return d, nil
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad age %q", s)
+	}
+	return time.Duration(n * float64(mult)), nil
+}
+
+// splitUnit splits "1.5GB" into "1.5" and "GB".
+func splitUnit(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := len(s)
+	for i > 0 && (s[i-1] < '0' || s[i-1] > '9') && s[i-1] != '.' {
+		i--
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i:])
+}
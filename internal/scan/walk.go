@@ -0,0 +1,541 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"icicle/internal/skip"
+)
+
+// Options tunes a single scan call. The zero value walks everything with the default worker count.
+type Options struct {
+	// Workers caps parallel directory readers; <= 0 falls back to ICICLE_SCAN_WORKERS or the CPU-based default.
+	Workers int
+	// MaxFiles stops the walk after this many files; <= 0 means no limit.
+	MaxFiles int
+	// Skip decides which directories are not descended into; nil applies the platform defaults.
+	Skip *skip.Policy
+	// FollowSymlinks counts symlinked files outside root and descends into symlinked directories.
+	// Directories are entered once per device and inode, so link cycles end.
+	FollowSymlinks bool
+	// Index, when set, replays directories whose mtime is unchanged and records the rest.
+	Index *Index
+	// SizeMode picks the size passed to callbacks and summed into Total.
+	SizeMode SizeMode
+	// TopBy picks which files the top-N lists of the Scan* functions keep.
+	TopBy TopOrder
+	// Filter drops files before they reach callbacks, totals and MaxFiles; nil keeps every file.
+	// Folders are still walked, so use Skip to prune whole trees.
+	Filter *Filter
+	// Strict aborts the walk on the first error other than a permission problem.
+	// By default such errors are collected in the result and the walk continues.
+	Strict bool
+	// AllLinks reports every name of a file with several hard links instead of only the first.
+	AllLinks bool
+}
+
+func (o Options) workers() int {
+	if o.Workers <= 0 {
+		return scanWorkers()
+	}
+	if o.Workers > 128 {
+		return 128
+	}
+	return o.Workers
+}
+
+// WalkAll walks the path and calls onFile for each file found.
+func WalkAll(root string, onFile func(path string, size int64)) error {
+	_, err := WalkAllContext(context.Background(), root, Options{}, onFile)
+	return err
+}
+
+// WalkAllLimit walks files up to maxFiles and then stops gracefully.
+func WalkAllLimit(root string, maxFiles int, onFile func(path string, size int64)) (int, error) {
+	return WalkAllContext(context.Background(), root, Options{MaxFiles: maxFiles}, onFile)
+}
+
+// WalkAllContext walks root in lexical order on the calling goroutine, so onFile needs no locking.
+// Workers is ignored. A root that is a regular file is reported as the only file. It returns the
+// number of files seen and stops with ctx.Err() once ctx is cancelled.
+func WalkAllContext(ctx context.Context, root string, opts Options, onFile func(path string, size int64)) (int, error) {
+	report := func(_ int, path string, size fileStat) {
+		onFile(path, size.pick(opts.SizeMode))
+	}
+	if info, err := os.Lstat(root); err == nil && info.Mode().IsRegular() {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		w := &walker{opts: opts, onFile: report}
+		size, _, _ := statFile(info)
+		w.file(0, filepath.Clean(root), size)
+		return int(w.seen.Load()), nil
+	}
+	res, err := walkFiles(ctx, root, opts, false, report)
+	return res.seen, err
+}
+
+// WalkConcurrentContext walks root with the parallel walker used by the Scan* functions.
+// onFile is called from several goroutines at once and must be safe for concurrent use.
+// It returns the number of files seen and whether opts.MaxFiles cut the walk short.
+func WalkConcurrentContext(ctx context.Context, root string, opts Options, onFile func(path string, size int64)) (int, bool, error) {
+	res, err := walkFiles(ctx, root, opts, true, func(_ int, path string, size fileStat) {
+		onFile(path, size.pick(opts.SizeMode))
+	})
+	return res.seen, res.limited, err
+}
+
+// WalkStats summarises a walk done by WalkInfoContext.
+type WalkStats struct {
+	Seen     int
+	Limited  bool
+	Symlinks []Symlink
+	Errors   ScanErrors
+}
+
+// WalkInfoContext is WalkConcurrentContext for callers that need file times or the walk summary.
+// onFile must be safe for concurrent use.
+func WalkInfoContext(ctx context.Context, root string, opts Options, onFile func(FileInfo)) (*WalkStats, error) {
+	return WalkTreeContext(ctx, root, opts, onFile, nil)
+}
+
+// WalkTreeContext is WalkInfoContext that also tells onDir about every directory, root included,
+// before it is read. Both callbacks must be safe for concurrent use; onDir may be nil.
+func WalkTreeContext(ctx context.Context, root string, opts Options, onFile func(FileInfo), onDir func(dir string)) (*WalkStats, error) {
+	var dirFunc func(int, string)
+	if onDir != nil {
+		dirFunc = func(_ int, dir string) { onDir(dir) }
+	}
+	res, err := walk(ctx, root, opts, true, func(_ int, path string, st fileStat) {
+		onFile(st.info(path, opts.SizeMode))
+	}, dirFunc)
+	if err != nil {
+		return nil, err
+	}
+	return &WalkStats{Seen: res.seen, Limited: res.limited, Symlinks: res.symlinks, Errors: res.errors}, nil
+}
+
+// shardFunc receives files together with the walker shard that found them.
+// A shard is owned by exactly one goroutine at a time, so per-shard state needs no locking.
+type shardFunc func(shard int, path string, size fileStat)
+
+// shardCount is the number of distinct shard ids walkFiles hands to onFile.
+func shardCount(opts Options, parallel bool) int {
+	if !parallel {
+		return 1
+	}
+	// Shard 0 is the calling goroutine; 1..workers are the spawn slots.
+	return opts.workers() + 1
+}
+
+type walker struct {
+	opts   Options
+	done   <-chan struct{}
+	onFile shardFunc
+	// onDir, when set, is told about every directory before it is read.
+	onDir func(shard int, dir string)
+	skip  *skip.Matcher
+	// slots hands out shard ids to spawned directory goroutines; nil keeps the whole walk on the caller's goroutine.
+	slots chan int
+	wg    sync.WaitGroup
+	seen  atomic.Int64
+	stop  atomic.Bool
+
+	root     string
+	errMu    sync.Mutex
+	firstErr error
+	errs     ScanErrors
+
+	// rootReal is the resolved root with a trailing separator, used to skip links back into the tree.
+	rootReal string
+	linkMu   sync.Mutex
+	linked   map[string]struct{}
+	symlinks []Symlink
+
+	// inodes holds files with several hard links that were already counted.
+	inodeMu sync.Mutex
+	inodes  map[inodeKey]struct{}
+}
+
+// walkResult summarises a finished walk.
+type walkResult struct {
+	seen     int
+	limited  bool
+	symlinks []Symlink
+	errors   ScanErrors
+}
+
+func walkFiles(ctx context.Context, root string, opts Options, parallel bool, onFile shardFunc) (walkResult, error) {
+	return walk(ctx, root, opts, parallel, onFile, nil)
+}
+
+func walk(ctx context.Context, root string, opts Options, parallel bool, onFile shardFunc, onDir func(shard int, dir string)) (walkResult, error) {
+	root = filepath.Clean(root)
+	w := &walker{
+		opts:   opts,
+		done:   ctx.Done(),
+		onFile: onFile,
+		onDir:  onDir,
+		skip:   opts.Skip.Bind(root),
+		root:   root,
+	}
+	if n := shardCount(opts, parallel); n > 1 {
+		w.slots = make(chan int, n-1)
+		for id := 1; id < n; id++ {
+			w.slots <- id
+		}
+	}
+	w.linked = map[string]struct{}{}
+	if opts.FollowSymlinks {
+		w.rootReal = root
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			w.rootReal = real
+		}
+		if !strings.HasSuffix(w.rootReal, string(filepath.Separator)) {
+			w.rootReal += string(filepath.Separator)
+		}
+	}
+
+	// Root scan runs in current goroutine; spawned subdir goroutines are tracked via WaitGroup.
+	w.walkDir(root, 0)
+	w.wg.Wait()
+
+	w.errMu.Lock()
+	err := w.firstErr
+	w.errMu.Unlock()
+	count := int(w.seen.Load())
+	limited := opts.MaxFiles > 0 && count >= opts.MaxFiles
+	if err == nil && !limited {
+		err = ctx.Err()
+	}
+	if opts.Index != nil {
+		opts.Index.commit(root, err == nil && !limited)
+	}
+	sort.Slice(w.symlinks, func(i, j int) bool { return w.symlinks[i].Path < w.symlinks[j].Path })
+	return walkResult{seen: count, limited: limited, symlinks: w.symlinks, errors: w.errs}, err
+}
+
+// stopped folds context cancellation into the shared stop flag.
+func (w *walker) stopped() bool {
+	if w.stop.Load() {
+		return true
+	}
+	select {
+	case <-w.done:
+		w.stop.Store(true)
+		return true
+	default:
+		return false
+	}
+}
+
+// pathErr records a per-path error. The walk goes on unless the root itself failed
+// or Options.Strict is set and the error is more than a permission problem.
+func (w *walker) pathErr(path string, dir bool, err error) {
+	pe := PathError{Path: path, Kind: classifyError(err), Dir: dir, Err: err}
+	w.errMu.Lock()
+	w.errs.add(pe)
+	w.errMu.Unlock()
+	if path == w.root || (w.opts.Strict && pe.Kind != ErrDenied) {
+		w.setErr(&pe)
+	}
+}
+
+func (w *walker) setErr(err error) {
+	if err == nil {
+		return
+	}
+	w.errMu.Lock()
+	if w.firstErr == nil {
+		w.firstErr = err
+	}
+	w.errMu.Unlock()
+	w.stop.Store(true)
+}
+
+func (w *walker) walkDir(dir string, shard int) {
+	if w.stopped() {
+		return
+	}
+	if w.opts.FollowSymlinks && !w.claimDir(dir) {
+		return
+	}
+	if w.onDir != nil {
+		w.onDir(shard, dir)
+	}
+	// rec collects the listing for the index; it is only stored once the whole directory was read.
+	var rec *indexDir
+	if idx := w.opts.Index; idx != nil {
+		info, err := os.Stat(dir)
+		if err != nil {
+			w.pathErr(dir, true, err)
+			return
+		}
+		if d := idx.lookup(dir, info.ModTime()); d != nil {
+			w.replay(dir, d, shard)
+			return
+		}
+		rec = &indexDir{ModTime: info.ModTime().UnixNano()}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.pathErr(dir, true, err)
+		return
+	}
+	if rec != nil {
+		rec.Entries = make([]indexEntry, 0, len(entries))
+	}
+	for _, e := range entries {
+		if w.stopped() {
+			return
+		}
+		name := e.Name()
+		full := fastJoin(dir, name)
+		if e.Type()&os.ModeSymlink != 0 {
+			if rec != nil {
+				rec.Entries = append(rec.Entries, indexEntry{Name: name, Kind: entryLink})
+			}
+			w.symlink(full, shard)
+			continue
+		}
+		if e.IsDir() {
+			if rec != nil {
+				rec.Entries = append(rec.Entries, indexEntry{Name: name, Kind: entryDir})
+			}
+			if w.skip.SkipDir(full, name) {
+				continue
+			}
+			w.descend(full, shard)
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			w.pathErr(full, false, err)
+			// Keep re-reading this directory until the file is readable again.
+			rec = nil
+			continue
+		}
+		size, key, multi := statFile(info)
+		if rec != nil {
+			ent := indexEntry{Name: name, Size: size.apparent, Alloc: size.allocated, ModTime: size.mtime, ATime: size.atime, Kind: entryFile}
+			if multi {
+				ent.Dev, ent.Ino = key.dev, key.ino
+			}
+			rec.Entries = append(rec.Entries, ent)
+		}
+		if multi && !w.opts.AllLinks && !w.claimInode(key) {
+			continue
+		}
+		if !w.file(shard, full, size) {
+			return
+		}
+	}
+	if rec != nil {
+		w.opts.Index.store(dir, rec)
+	}
+}
+
+// replay walks a directory from its indexed listing; subdirectories are still checked against the disk.
+func (w *walker) replay(dir string, d *indexDir, shard int) {
+	for _, e := range d.Entries {
+		if w.stopped() {
+			return
+		}
+		full := fastJoin(dir, e.Name)
+		switch e.Kind {
+		case entryLink:
+			w.symlink(full, shard)
+		case entryDir:
+			if !w.skip.SkipDir(full, e.Name) {
+				w.descend(full, shard)
+			}
+		default:
+			if e.Ino != 0 && !w.opts.AllLinks && !w.claimInode(inodeKey{dev: e.Dev, ino: e.Ino}) {
+				continue
+			}
+			if !w.file(shard, full, fileStat{apparent: e.Size, allocated: e.Alloc, mtime: e.ModTime, atime: e.ATime}) {
+				return
+			}
+		}
+	}
+}
+
+// descend walks a subdirectory in parallel when a slot is free and inline otherwise.
+func (w *walker) descend(dir string, shard int) {
+	if w.slots == nil {
+		w.walkDir(dir, shard)
+		return
+	}
+	// Try to process subdir in parallel; fallback to inline walk to avoid deadlocks.
+	select {
+	case id := <-w.slots:
+		w.wg.Add(1)
+		go func(p string) {
+			defer func() {
+				w.slots <- id
+				w.wg.Done()
+			}()
+			w.walkDir(p, id)
+		}(dir)
+	default:
+		w.walkDir(dir, shard)
+	}
+}
+
+// file reports one file and returns false once the MaxFiles cap is reached.
+// Files rejected by Options.Filter are dropped without counting.
+func (w *walker) file(shard int, path string, st fileStat) bool {
+	if f := w.opts.Filter; f != nil && !f.Match(path, st.pick(w.opts.SizeMode), time.Unix(0, st.mtime)) {
+		return true
+	}
+	w.onFile(shard, path, st)
+	n := int(w.seen.Add(1))
+	if w.opts.MaxFiles > 0 && n >= w.opts.MaxFiles {
+		w.stop.Store(true)
+		return false
+	}
+	return true
+}
+
+// symlink reports a link met during the walk and follows it when enabled.
+func (w *walker) symlink(path string, shard int) {
+	link := Symlink{Path: path}
+	link.Target, _ = os.Readlink(path)
+	defer func() { w.addSymlink(link) }()
+	if !w.opts.FollowSymlinks {
+		link.Reason = "not followed"
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		link.Reason = "dangling"
+		return
+	}
+	if w.insideRoot(path) {
+		// The target is walked through its real path already.
+		link.Reason = "target inside root"
+		return
+	}
+	if !info.IsDir() {
+		size, key, multi := statFile(info)
+		if multi && !w.claimInode(key) {
+			link.Reason = "already counted"
+			return
+		}
+		link.Followed = true
+		w.file(shard, path, size)
+		return
+	}
+	if key, ok := dirKey(info); ok {
+		// walkDir claims the identity; a claimed target is an ancestor (cycle) or was walked elsewhere.
+		if w.inodeClaimed(key) {
+			link.Reason = "already walked"
+			return
+		}
+	} else if !w.claimLinkTarget(path) {
+		link.Reason = "already walked"
+		return
+	}
+	link.Followed = true
+	w.descend(path, shard)
+}
+
+func (w *walker) addSymlink(link Symlink) {
+	w.linkMu.Lock()
+	if len(w.symlinks) < maxSymlinks {
+		w.symlinks = append(w.symlinks, link)
+	}
+	w.linkMu.Unlock()
+}
+
+// insideRoot reports whether a link resolves into the walked tree, where the target is counted already.
+func (w *walker) insideRoot(path string) bool {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	return real+string(filepath.Separator) == w.rootReal || strings.HasPrefix(real, w.rootReal)
+}
+
+// claimLinkTarget returns true the first time a linked directory outside root is seen.
+// It is the fallback for platforms without inode numbers.
+func (w *walker) claimLinkTarget(path string) bool {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	w.linkMu.Lock()
+	defer w.linkMu.Unlock()
+	if _, ok := w.linked[real]; ok {
+		return false
+	}
+	w.linked[real] = struct{}{}
+	return true
+}
+
+// claimDir marks a directory as walked by device and inode. It returns false for a directory
+// that was already entered, which breaks symlink cycles.
+func (w *walker) claimDir(dir string) bool {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return true
+	}
+	key, ok := dirKey(info)
+	if !ok {
+		return true
+	}
+	return w.claimInode(key)
+}
+
+// inodeClaimed reports whether key was already claimed in this walk.
+func (w *walker) inodeClaimed(key inodeKey) bool {
+	w.inodeMu.Lock()
+	defer w.inodeMu.Unlock()
+	_, ok := w.inodes[key]
+	return ok
+}
+
+// claimInode returns true the first time a hard-linked file is seen in this walk.
+func (w *walker) claimInode(key inodeKey) bool {
+	w.inodeMu.Lock()
+	defer w.inodeMu.Unlock()
+	if w.inodes == nil {
+		w.inodes = map[inodeKey]struct{}{}
+	}
+	if _, ok := w.inodes[key]; ok {
+		return false
+	}
+	w.inodes[key] = struct{}{}
+	return true
+}
+
+func scanWorkers() int {
+	// IO-bound scanning benefits from higher concurrency than CPU count.
+	workers := runtime.NumCPU() * 2
+	if workers < 8 {
+		workers = 8
+	}
+	if workers > 32 {
+		workers = 32
+	}
+	if raw := strings.TrimSpace(os.Getenv("ICICLE_SCAN_WORKERS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil {
+			if n < 1 {
+				n = 1
+			}
+			if n > 128 {
+				n = 128
+			}
+			workers = n
+		}
+	}
+	return workers
+}
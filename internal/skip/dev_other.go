@@ -0,0 +1,10 @@
+//go:build !unix
+
+package skip
+
+import "os"
+
+// deviceOf is unknown here; other volumes are only reachable through reparse points, which walks do not follow by default.
+func deviceOf(os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package skip
+
+import (
+	"os"
+	"syscall"
+)
+
+func deviceOf(info os.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
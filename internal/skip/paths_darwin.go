@@ -0,0 +1,4 @@
+package skip
+
+// systemPaths holds devfs and the firmlinked data volume, which would count user data twice.
+var systemPaths = []string{"/dev", "/System/Volumes/Data"}
@@ -0,0 +1,4 @@
+package skip
+
+// systemPaths are pseudo filesystems that report fake sizes or never end.
+var systemPaths = []string{"/proc", "/sys", "/dev"}
@@ -0,0 +1,5 @@
+//go:build !linux && !darwin
+
+package skip
+
+var systemPaths []string
@@ -0,0 +1,160 @@
+// Package skip decides which directories scans, watchers and cleanups leave alone.
+package skip
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"icicle/internal/appdir"
+)
+
+// systemNames are NTFS/Windows system folders; they are skipped wherever the volume is mounted.
+var systemNames = []string{
+	"$recycle.bin",
+	"system volume information",
+	"$extend",
+	"$winreagent",
+	"windowsapps",
+	"msocache",
+}
+
+// Policy is the shared skip configuration. The zero value applies the platform defaults only.
+type Policy struct {
+	// Patterns are extra path.Match globs. A pattern without a separator is matched against
+	// the directory name, otherwise against the full path (with forward slashes).
+	Patterns []string `json:"patterns"`
+	// NoDefaults drops the built-in system folder and path lists.
+	NoDefaults bool `json:"noDefaults"`
+	// OneFileSystem keeps a walk on the filesystem of its root, like du -x.
+	OneFileSystem bool `json:"oneFileSystem"`
+}
+
+// ConfigPath is the optional skip.json next to the other icicle settings.
+func ConfigPath() (string, error) {
+	dir, err := appdir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "skip.json"), nil
+}
+
+// Load reads skip.json; a missing file yields the default policy.
+func Load() (*Policy, error) {
+	p := &Policy{}
+	file, err := ConfigPath()
+	if err != nil {
+		return p, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return &Policy{}, err
+	}
+	return p, nil
+}
+
+// SkipName reports whether a directory with this name is skipped regardless of where it lives.
+func (p *Policy) SkipName(name string) bool {
+	name = strings.TrimSpace(name)
+	if p == nil || !p.NoDefaults {
+		lower := strings.ToLower(name)
+		for _, s := range systemNames {
+			if lower == s {
+				return true
+			}
+		}
+	}
+	if p == nil {
+		return false
+	}
+	for _, pat := range p.Patterns {
+		if strings.ContainsAny(pat, `/\`) {
+			continue
+		}
+		if matchFold(pat, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipPath reports whether the directory at dir is skipped by name, path pattern or platform default.
+func (p *Policy) SkipPath(dir string) bool {
+	dir = filepath.Clean(dir)
+	return p.SkipName(filepath.Base(dir)) || p.skipFullPath(dir)
+}
+
+func (p *Policy) skipFullPath(dir string) bool {
+	if p == nil || !p.NoDefaults {
+		for _, s := range systemPaths {
+			if dir == s {
+				return true
+			}
+		}
+	}
+	if p == nil {
+		return false
+	}
+	slashed := filepath.ToSlash(dir)
+	for _, pat := range p.Patterns {
+		if strings.ContainsAny(pat, `/\`) && matchFold(filepath.ToSlash(pat), slashed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bind prepares the policy for one walk rooted at root.
+func (p *Policy) Bind(root string) *Matcher {
+	m := &Matcher{p: p}
+	if p != nil && p.OneFileSystem {
+		if info, err := os.Stat(root); err == nil {
+			m.dev, m.hasDev = deviceOf(info)
+		}
+	}
+	return m
+}
+
+// Matcher is a Policy bound to a walk root. It is safe for concurrent use.
+type Matcher struct {
+	p      *Policy
+	dev    uint64
+	hasDev bool
+}
+
+// SkipDir reports whether the walk should not descend into the directory at path.
+// The name is passed separately so callers that already have it avoid re-splitting the path.
+func (m *Matcher) SkipDir(dir string, name string) bool {
+	if m.p.SkipName(name) || m.p.skipFullPath(dir) {
+		return true
+	}
+	if m.hasDev {
+		info, err := os.Lstat(dir)
+		if err != nil {
+			return false
+		}
+		if dev, ok := deviceOf(info); ok && dev != m.dev {
+			return true
+		}
+	}
+	return false
+}
+
+func matchFold(pattern, name string) bool {
+	if runtime.GOOS == "windows" {
+		pattern = strings.ToLower(pattern)
+		name = strings.ToLower(name)
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
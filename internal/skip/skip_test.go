@@ -0,0 +1,56 @@
+package skip
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSkipName(t *testing.T) {
+	var def *Policy
+	if !def.SkipName("$RECYCLE.BIN") || def.SkipName("Videos") {
+		t.Fatalf("default name list mismatch")
+	}
+	p := &Policy{Patterns: []string{"node_modules", ".cache*"}}
+	cases := map[string]bool{
+		"node_modules":              true,
+		".cache-v2":                 true,
+		"src":                       false,
+		"System Volume Information": true,
+	}
+	for name, want := range cases {
+		if got := p.SkipName(name); got != want {
+			t.Fatalf("SkipName(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if (&Policy{NoDefaults: true}).SkipName("$recycle.bin") {
+		t.Fatalf("NoDefaults still skips system folders")
+	}
+}
+
+func TestSkipPath(t *testing.T) {
+	root := t.TempDir()
+	p := &Policy{Patterns: []string{filepath.ToSlash(root) + "/backup/*"}}
+	if !p.SkipPath(filepath.Join(root, "backup", "2024")) {
+		t.Fatalf("path pattern not applied")
+	}
+	if p.SkipPath(filepath.Join(root, "photos", "2024")) {
+		t.Fatalf("path pattern too broad")
+	}
+	if runtime.GOOS == "linux" && !(*Policy)(nil).SkipPath("/proc") {
+		t.Fatalf("/proc not skipped by default")
+	}
+}
+
+func TestMatcherSameFileSystem(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	m := (&Policy{OneFileSystem: true}).Bind(root)
+	if m.SkipDir(sub, "sub") {
+		t.Fatalf("directory on the root filesystem was skipped")
+	}
+}